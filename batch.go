@@ -0,0 +1,229 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type BatchProcessingStatus string
+
+const (
+	BatchProcessingStatusInProgress BatchProcessingStatus = "in_progress"
+	BatchProcessingStatusCanceling  BatchProcessingStatus = "canceling"
+	BatchProcessingStatusEnded      BatchProcessingStatus = "ended"
+)
+
+type BatchResultType string
+
+const (
+	BatchResultTypeSucceeded BatchResultType = "succeeded"
+	BatchResultTypeErrored   BatchResultType = "errored"
+	BatchResultTypeCanceled  BatchResultType = "canceled"
+	BatchResultTypeExpired   BatchResultType = "expired"
+)
+
+// BatchRequest docs: https://docs.anthropic.com/en/api/creating-message-batches
+type BatchRequest struct {
+	Requests []BatchRequestItem `json:"requests"`
+}
+
+type BatchRequestItem struct {
+	// CustomID identifies the request in the batch results, it must be unique within the batch.
+	CustomID string          `json:"custom_id"`
+	Params   MessagesRequest `json:"params"`
+}
+
+type BatchRequestCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+type MessageBatch struct {
+	ID                string                `json:"id"`
+	Type              string                `json:"type"`
+	ProcessingStatus  BatchProcessingStatus `json:"processing_status"`
+	RequestCounts     BatchRequestCounts    `json:"request_counts"`
+	EndedAt           *time.Time            `json:"ended_at"`
+	CreatedAt         time.Time             `json:"created_at"`
+	ExpiresAt         time.Time             `json:"expires_at"`
+	ArchivedAt        *time.Time            `json:"archived_at"`
+	CancelInitiatedAt *time.Time            `json:"cancel_initiated_at"`
+	ResultsURL        *string               `json:"results_url"`
+}
+
+type BatchResponse struct {
+	httpHeader
+
+	MessageBatch
+}
+
+type ListBatchesRequest = ListParams
+
+type ListBatchesResponse = Page[MessageBatch]
+
+type DeleteBatchResponse struct {
+	httpHeader
+
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// BatchResult is a single line of the batch results file.
+type BatchResult struct {
+	CustomID string          `json:"custom_id"`
+	Result   BatchResultData `json:"result"`
+}
+
+type BatchResultData struct {
+	Type BatchResultType `json:"type"`
+	// Message is set when Type is succeeded.
+	Message *MessagesResponse `json:"message,omitempty"`
+	// Error is set when Type is errored.
+	Error *ErrorResponse `json:"error,omitempty"`
+}
+
+func (c *Client) CreateBatch(ctx context.Context, request BatchRequest) (response BatchResponse, err error) {
+	req, err := c.newRequest(ctx, http.MethodPost, "/messages/batches", &request, withBetaVersion(BetaMessageBatches20240924))
+	if err != nil {
+		return
+	}
+
+	err = c.sendRequest(req, &response)
+	return
+}
+
+func (c *Client) RetrieveBatch(ctx context.Context, batchID string) (response BatchResponse, err error) {
+	req, err := c.newRequest(ctx, http.MethodGet, batchURLSuffix(batchID), nil, withBetaVersion(BetaMessageBatches20240924))
+	if err != nil {
+		return
+	}
+
+	err = c.sendRequest(req, &response)
+	return
+}
+
+// ListBatches lists the batches of the workspace, the most recently created first.
+func (c *Client) ListBatches(ctx context.Context, request ListBatchesRequest) (response ListBatchesResponse, err error) {
+	req, err := c.newRequest(ctx, http.MethodGet, request.urlSuffix("/messages/batches"), nil, withBetaVersion(BetaMessageBatches20240924))
+	if err != nil {
+		return
+	}
+
+	err = c.sendRequest(req, &response)
+	return
+}
+
+// ListAllBatches returns an iterator over the batches of all pages, starting at request.
+func (c *Client) ListAllBatches(ctx context.Context, request ListBatchesRequest) *PageIterator[MessageBatch] {
+	return newPageIterator(ctx, request, c.ListBatches)
+}
+
+// CancelBatch initiates the cancellation of a batch that is still processing.
+// The batch has the canceling status until the requests in flight are done.
+func (c *Client) CancelBatch(ctx context.Context, batchID string) (response BatchResponse, err error) {
+	req, err := c.newRequest(ctx, http.MethodPost, batchURLSuffix(batchID)+"/cancel", nil, withBetaVersion(BetaMessageBatches20240924))
+	if err != nil {
+		return
+	}
+
+	err = c.sendRequest(req, &response)
+	return
+}
+
+// DeleteBatch deletes a batch once it has finished processing.
+func (c *Client) DeleteBatch(ctx context.Context, batchID string) (response DeleteBatchResponse, err error) {
+	req, err := c.newRequest(ctx, http.MethodDelete, batchURLSuffix(batchID), nil, withBetaVersion(BetaMessageBatches20240924))
+	if err != nil {
+		return
+	}
+
+	err = c.sendRequest(req, &response)
+	return
+}
+
+// BatchResults streams the results of an ended batch. The caller must close the returned stream.
+// Results are not guaranteed to be in the same order as the requests, use BatchResult.CustomID to match them.
+func (c *Client) BatchResults(ctx context.Context, batchID string) (*BatchResultsStream, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, batchURLSuffix(batchID)+"/results", nil, withBetaVersion(BetaMessageBatches20240924))
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &BatchResultsStream{}
+	resp, err := c.doRequest(req, stream, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stream.body = resp.Body
+	stream.reader = bufio.NewReader(resp.Body)
+	return stream, nil
+}
+
+func batchURLSuffix(batchID string) string {
+	return "/messages/batches/" + url.PathEscape(batchID)
+}
+
+// BatchResultsStream decodes the JSONL batch results one line at a time.
+type BatchResultsStream struct {
+	httpHeader
+
+	body   io.ReadCloser
+	reader *bufio.Reader
+	result BatchResult
+	err    error
+}
+
+// Next decodes the next result, it returns false when there are no more results or an error occurred.
+func (s *BatchResultsStream) Next() bool {
+	if s.err != nil {
+		return false
+	}
+
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			s.err = err
+			return false
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			var result BatchResult
+			if unmarshalErr := json.Unmarshal(line, &result); unmarshalErr != nil {
+				s.err = unmarshalErr
+				return false
+			}
+			s.result = result
+			return true
+		}
+
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// Result returns the result decoded by the last call to Next.
+func (s *BatchResultsStream) Result() BatchResult {
+	return s.result
+}
+
+// Err returns the error that stopped Next, if any.
+func (s *BatchResultsStream) Err() error {
+	return s.err
+}
+
+func (s *BatchResultsStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,193 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/liushuangls/go-anthropic/v2/internal/test"
+	"github.com/liushuangls/go-anthropic/v2/internal/test/checks"
+)
+
+const testBatchJSON = `{
+	"id": "msgbatch_013Zva2CMHLNnXjNJJKqJ2EF",
+	"type": "message_batch",
+	"processing_status": "%s",
+	"request_counts": {"processing": 0, "succeeded": 1, "errored": 1, "canceled": 0, "expired": 0},
+	"ended_at": null,
+	"created_at": "2024-09-24T18:37:24.100435Z",
+	"expires_at": "2024-09-25T18:37:24.100435Z",
+	"archived_at": null,
+	"cancel_initiated_at": null,
+	"results_url": null
+}`
+
+func newBatchTestClient(t *testing.T) *anthropic.Client {
+	t.Helper()
+
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages/batches", handleBatchesEndpoint)
+	server.RegisterHandler("/v1/messages/batches/msgbatch_013Zva2CMHLNnXjNJJKqJ2EF", handleBatchEndpoint)
+	server.RegisterHandler("/v1/messages/batches/msgbatch_013Zva2CMHLNnXjNJJKqJ2EF/cancel", handleBatchCancelEndpoint)
+	server.RegisterHandler("/v1/messages/batches/msgbatch_013Zva2CMHLNnXjNJJKqJ2EF/results", handleBatchResultsEndpoint)
+	return server.NewClient(t)
+}
+
+func TestCreateBatch(t *testing.T) {
+	client := newBatchTestClient(t)
+
+	resp, err := client.CreateBatch(context.Background(), anthropic.BatchRequest{
+		Requests: []anthropic.BatchRequestItem{
+			{CustomID: "my-first-request", Params: newTestMessagesRequest()},
+			{CustomID: "my-second-request", Params: newTestMessagesRequest()},
+		},
+	})
+	checks.NoError(t, err, "CreateBatch error")
+	if resp.ID != "msgbatch_013Zva2CMHLNnXjNJJKqJ2EF" || resp.ProcessingStatus != anthropic.BatchProcessingStatusInProgress {
+		t.Fatalf("unexpected batch: %+v", resp.MessageBatch)
+	}
+}
+
+func TestRetrieveBatch(t *testing.T) {
+	client := newBatchTestClient(t)
+
+	resp, err := client.RetrieveBatch(context.Background(), "msgbatch_013Zva2CMHLNnXjNJJKqJ2EF")
+	checks.NoError(t, err, "RetrieveBatch error")
+	if resp.RequestCounts.Succeeded != 1 || resp.CreatedAt.IsZero() {
+		t.Fatalf("unexpected batch: %+v", resp.MessageBatch)
+	}
+
+	_, err = client.RetrieveBatch(context.Background(), "msgbatch_unknown")
+	var e *anthropic.Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestListBatches(t *testing.T) {
+	client := newBatchTestClient(t)
+
+	resp, err := client.ListBatches(context.Background(), anthropic.ListBatchesRequest{
+		AfterID: "msgbatch_0",
+		Limit:   1,
+	})
+	checks.NoError(t, err, "ListBatches error")
+	if len(resp.Data) != 1 || !resp.HasMore || resp.LastID != "msgbatch_013Zva2CMHLNnXjNJJKqJ2EF" {
+		t.Fatalf("unexpected list: %+v", resp)
+	}
+}
+
+func TestCancelBatch(t *testing.T) {
+	client := newBatchTestClient(t)
+
+	resp, err := client.CancelBatch(context.Background(), "msgbatch_013Zva2CMHLNnXjNJJKqJ2EF")
+	checks.NoError(t, err, "CancelBatch error")
+	if resp.ProcessingStatus != anthropic.BatchProcessingStatusCanceling {
+		t.Fatalf("unexpected status: %s", resp.ProcessingStatus)
+	}
+}
+
+func TestDeleteBatch(t *testing.T) {
+	client := newBatchTestClient(t)
+
+	resp, err := client.DeleteBatch(context.Background(), "msgbatch_013Zva2CMHLNnXjNJJKqJ2EF")
+	checks.NoError(t, err, "DeleteBatch error")
+	if resp.Type != "message_batch_deleted" {
+		t.Fatalf("unexpected type: %s", resp.Type)
+	}
+}
+
+func TestBatchResults(t *testing.T) {
+	client := newBatchTestClient(t)
+
+	stream, err := client.BatchResults(context.Background(), "msgbatch_013Zva2CMHLNnXjNJJKqJ2EF")
+	checks.NoError(t, err, "BatchResults error")
+	defer stream.Close()
+
+	results := map[string]anthropic.BatchResult{}
+	for stream.Next() {
+		results[stream.Result().CustomID] = stream.Result()
+	}
+	checks.NoError(t, stream.Err(), "BatchResults stream error")
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+
+	first := results["my-first-request"].Result
+	if first.Type != anthropic.BatchResultTypeSucceeded || first.Message == nil || first.Message.GetFirstContentText() != "Hello!" {
+		t.Fatalf("unexpected first result: %+v", first)
+	}
+
+	second := results["my-second-request"].Result
+	if second.Type != anthropic.BatchResultTypeErrored || second.Error == nil || !second.Error.Error.IsInvalidRequestErr() {
+		t.Fatalf("unexpected second result: %+v", second)
+	}
+}
+
+func TestBatchVertexAINotSupported(t *testing.T) {
+	client := anthropic.NewClient(test.GetTestToken(), anthropic.WithVertexAI("project", "us-east5"))
+
+	_, err := client.RetrieveBatch(context.Background(), "msgbatch_013Zva2CMHLNnXjNJJKqJ2EF")
+	checks.ErrorIs(t, err, anthropic.ErrVertexAINotSupported, "should not be supported by Vertex AI")
+}
+
+func writeTestBatch(w http.ResponseWriter, status anthropic.BatchProcessingStatus) {
+	_, _ = w.Write([]byte(fmt.Sprintf(testBatchJSON, status)))
+}
+
+func handleBatchesEndpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("anthropic-beta") != anthropic.BetaMessageBatches20240924 {
+		http.Error(w, "missing beta header", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var req anthropic.BatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Requests) == 0 {
+			http.Error(w, "could not read request", http.StatusBadRequest)
+			return
+		}
+		writeTestBatch(w, anthropic.BatchProcessingStatusInProgress)
+	case http.MethodGet:
+		if r.URL.Query().Get("after_id") != "msgbatch_0" || r.URL.Query().Get("limit") != "1" {
+			http.Error(w, "unexpected query", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[` + fmt.Sprintf(testBatchJSON, anthropic.BatchProcessingStatusEnded) + `],` +
+			`"has_more":true,"first_id":"msgbatch_013Zva2CMHLNnXjNJJKqJ2EF","last_id":"msgbatch_013Zva2CMHLNnXjNJJKqJ2EF"}`))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleBatchEndpoint(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeTestBatch(w, anthropic.BatchProcessingStatusEnded)
+	case http.MethodDelete:
+		_, _ = w.Write([]byte(`{"id":"msgbatch_013Zva2CMHLNnXjNJJKqJ2EF","type":"message_batch_deleted"}`))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleBatchCancelEndpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeTestBatch(w, anthropic.BatchProcessingStatusCanceling)
+}
+
+func handleBatchResultsEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/binary")
+	_, _ = w.Write([]byte(`{"custom_id":"my-second-request","result":{"type":"errored","error":{"type":"error","error":{"type":"invalid_request_error","message":"Validation error"}}}}` + "\n"))
+	_, _ = w.Write([]byte("\n"))
+	_, _ = w.Write([]byte(`{"custom_id":"my-first-request","result":{"type":"succeeded","message":{"id":"msg_014VwiXbi91y3JMjcpyGBHX5","type":"message","role":"assistant","model":"claude-3-5-sonnet-20240620","content":[{"type":"text","text":"Hello!"}],"stop_reason":"end_turn","stop_sequence":null,"usage":{"input_tokens":11,"output_tokens":36}}}}`))
+}
@@ -0,0 +1,189 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2/internal/eventstream"
+	"github.com/liushuangls/go-anthropic/v2/internal/sigv4"
+)
+
+const bedrockService = "bedrock"
+
+// AWSCredentials are the AWS credentials the Bedrock requests are signed with.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials.
+	SessionToken string
+}
+
+// AWSCredentialsProvider provides the credentials to sign a Bedrock request with. It is called for
+// every request, so temporary credentials can be refreshed.
+type AWSCredentialsProvider interface {
+	Retrieve(ctx context.Context) (AWSCredentials, error)
+}
+
+// AWSCredentialsProviderFunc adapts a function to an AWSCredentialsProvider,
+// e.g. to retrieve the credentials with the AWS SDK.
+type AWSCredentialsProviderFunc func(ctx context.Context) (AWSCredentials, error)
+
+func (f AWSCredentialsProviderFunc) Retrieve(ctx context.Context) (AWSCredentials, error) {
+	return f(ctx)
+}
+
+// StaticAWSCredentials is an AWSCredentialsProvider always providing the same credentials.
+type StaticAWSCredentials AWSCredentials
+
+func (c StaticAWSCredentials) Retrieve(context.Context) (AWSCredentials, error) {
+	return AWSCredentials(c), nil
+}
+
+// bedrockModelPath returns the model path segment of the Bedrock URLs. The colon of the
+// model versions is escaped too, as the AWS SDKs do.
+func bedrockModelPath(modelID string) string {
+	return strings.ReplaceAll(url.PathEscape(modelID), ":", "%3A")
+}
+
+// bedrockIgnoredBetas are the beta versions of features now generally available, which are not
+// passed to Bedrock.
+var bedrockIgnoredBetas = []string{BetaTools20240404, BetaTools20240516, BetaPromptCaching20240731}
+
+// bedrockRequestBody removes the fields Bedrock rejects from the request body: the model is
+// in the URL, and streaming depends on the endpoint. Bedrock takes the beta versions in the body
+// as anthropic_beta instead of the anthropic-beta header.
+func bedrockRequestBody(body []byte, betaVersions []string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "model")
+	delete(fields, "stream")
+
+	betaVersions = slices.DeleteFunc(slices.Clone(betaVersions), func(version string) bool {
+		return slices.Contains(bedrockIgnoredBetas, version)
+	})
+	if len(betaVersions) > 0 {
+		betas, err := json.Marshal(betaVersions)
+		if err != nil {
+			return nil, err
+		}
+		fields["anthropic_beta"] = betas
+	}
+	return json.Marshal(fields)
+}
+
+// signBedrockRequest signs the request with the credentials of the client's provider.
+func (c *Client) signBedrockRequest(req *http.Request, body []byte) error {
+	if c.config.bedrockCredentials == nil {
+		return errors.New("bedrock: no credentials provider")
+	}
+	creds, err := c.config.bedrockCredentials.Retrieve(req.Context())
+	if err != nil {
+		return err
+	}
+	sigv4.Sign(req, body, sigv4.Credentials(creds), c.config.bedrockRegion, bedrockService, time.Now())
+	return nil
+}
+
+// bedrockErrorTypes maps the lowercased Bedrock exception names to the Anthropic error types.
+var bedrockErrorTypes = map[string]ErrType{
+	"validationexception":           ErrTypeInvalidRequest,
+	"unrecognizedclientexception":   ErrTypeAuthentication,
+	"invalidsignatureexception":     ErrTypeAuthentication,
+	"incompletesignature":           ErrTypeAuthentication,
+	"expiredtokenexception":         ErrTypeAuthentication,
+	"accessdeniedexception":         ErrTypePermission,
+	"resourcenotfoundexception":     ErrTypeNotFound,
+	"throttlingexception":           ErrTypeRateLimit,
+	"servicequotaexceededexception": ErrTypeRateLimit,
+	"serviceunavailableexception":   ErrTypeOverloaded,
+	"modelnotreadyexception":        ErrTypeOverloaded,
+	"internalserverexception":       ErrTypeApi,
+	"modeltimeoutexception":         ErrTypeApi,
+	"modelerrorexception":           ErrTypeApi,
+	"modelstreamerrorexception":     ErrTypeApi,
+}
+
+// bedrockErrorType returns the Anthropic error type of a Bedrock exception, e.g.
+// "ValidationException:http://internal.amazon.com/coral/com.amazon.bedrock/" from the
+// x-amzn-ErrorType header, falling back to the status code for unknown exceptions.
+func bedrockErrorType(exception string, statusCode int) ErrType {
+	name, _, _ := strings.Cut(exception, ":")
+	if errType, ok := bedrockErrorTypes[strings.ToLower(name)]; ok {
+		return errType
+	}
+	return errTypeFromStatusCode(statusCode)
+}
+
+// parseBedrockError decodes a Bedrock error body, {"message":"..."}, into an *APIError.
+func parseBedrockError(statusCode int, exception string, body []byte) error {
+	var errRes struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &errRes); err != nil {
+		return &RequestError{
+			StatusCode: statusCode,
+			Err:        err,
+			RawBody:    body,
+		}
+	}
+	return &APIError{
+		Type:    bedrockErrorType(exception, statusCode),
+		Message: errRes.Message,
+	}
+}
+
+// bedrockStreamDecoder reads the Anthropic events from the chunks of a Bedrock response stream.
+type bedrockStreamDecoder struct {
+	resp    *http.Response
+	decoder *eventstream.Decoder
+}
+
+func newBedrockStreamDecoder(resp *http.Response) *bedrockStreamDecoder {
+	return &bedrockStreamDecoder{resp: resp, decoder: eventstream.NewDecoder(resp.Body)}
+}
+
+func (d *bedrockStreamDecoder) next() (string, []byte, error) {
+	msg, err := d.decoder.Next()
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch msg.Header(":message-type") {
+	case "event":
+		if msg.Header(":event-type") != "chunk" {
+			return "", nil, nil
+		}
+		// the event JSON is base64 encoded, which encoding/json decodes into a []byte
+		var chunk struct {
+			Bytes []byte `json:"bytes"`
+		}
+		if err := json.Unmarshal(msg.Payload, &chunk); err != nil {
+			return "", nil, err
+		}
+		var event struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(chunk.Bytes, &event); err != nil {
+			return "", nil, err
+		}
+		return event.Type, chunk.Bytes, nil
+	case "exception":
+		exception := msg.Header(":exception-type")
+		return "", nil, newError(d.resp, msg.Payload, parseBedrockError(d.resp.StatusCode, exception, msg.Payload))
+	case "error":
+		return "", nil, newError(d.resp, msg.Payload, &APIError{
+			Type:    bedrockErrorType(msg.Header(":error-code"), 0),
+			Message: msg.Header(":error-message"),
+		})
+	default:
+		return "", nil, nil
+	}
+}
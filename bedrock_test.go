@@ -0,0 +1,283 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/liushuangls/go-anthropic/v2/internal/eventstream"
+	"github.com/liushuangls/go-anthropic/v2/internal/sigv4"
+	"github.com/liushuangls/go-anthropic/v2/internal/test/checks"
+)
+
+var testBedrockCredentials = anthropic.StaticAWSCredentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	SessionToken:    "session-token",
+}
+
+// checkBedrockRequest checks the request is signed with the test credentials, and returns its body.
+func checkBedrockRequest(t *testing.T, r *http.Request, wantPath string) map[string]any {
+	t.Helper()
+
+	if r.URL.EscapedPath() != wantPath {
+		t.Errorf("path = %q, want %q", r.URL.EscapedPath(), wantPath)
+	}
+	if r.Header.Get("X-Api-Key") != "" {
+		t.Error("the API key header should not be sent to Bedrock")
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedAt, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		t.Fatalf("invalid X-Amz-Date: %v", err)
+	}
+	want, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigv4.Sign(want, body, sigv4.Credentials(testBedrockCredentials), "us-east-1", "bedrock", signedAt)
+	if got := r.Header.Get("Authorization"); got != want.Header.Get("Authorization") {
+		t.Errorf("Authorization = %q\nwant %q", got, want.Header.Get("Authorization"))
+	}
+	if got := r.Header.Get("X-Amz-Security-Token"); got != "session-token" {
+		t.Errorf("X-Amz-Security-Token = %q", got)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(body, &fields); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	if fields["anthropic_version"] != anthropic.APIVersionBedrock20230531 {
+		t.Errorf("anthropic_version = %v", fields["anthropic_version"])
+	}
+	for _, key := range []string{"model", "stream"} {
+		if _, ok := fields[key]; ok {
+			t.Errorf("the body should not have the %q field", key)
+		}
+	}
+	return fields
+}
+
+func TestBedrockMessages(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checkBedrockRequest(t, r, "/model/anthropic.claude-3-5-sonnet-20240620-v1%3A0/invoke")
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20240620",` +
+			`"content":[{"type":"text","text":"Hello!"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":3}}`))
+	}))
+	defer ts.Close()
+
+	client := anthropic.NewClient("", anthropic.WithBedrock("us-east-1", testBedrockCredentials), anthropic.WithBaseURL(ts.URL))
+
+	resp, err := client.CreateMessages(context.Background(), anthropic.MessagesRequest{
+		Model:     anthropic.ModelClaude35Sonnet20240620,
+		Messages:  []anthropic.Message{anthropic.NewUserTextMessage("Hello")},
+		MaxTokens: 100,
+	})
+	checks.NoError(t, err, "CreateMessages error")
+	if resp.GetFirstContentText() != "Hello!" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestBedrockModelPassthrough(t *testing.T) {
+	// inference profile ARNs are sent as is, with their slash escaped
+	model := "arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.anthropic.claude-3-5-sonnet-20240620-v1:0"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checkBedrockRequest(t, r, "/model/arn%3Aaws%3Abedrock%3Aus-east-1%3A123456789012%3Ainference-profile%2Fus.anthropic.claude-3-5-sonnet-20240620-v1%3A0/invoke")
+		_, _ = w.Write([]byte(`{"type":"message","content":[]}`))
+	}))
+	defer ts.Close()
+
+	client := anthropic.NewClient("", anthropic.WithBedrock("us-east-1", testBedrockCredentials), anthropic.WithBaseURL(ts.URL))
+
+	_, err := client.CreateMessages(context.Background(), anthropic.MessagesRequest{
+		Model:     model,
+		Messages:  []anthropic.Message{anthropic.NewUserTextMessage("Hello")},
+		MaxTokens: 100,
+	})
+	checks.NoError(t, err, "CreateMessages error")
+}
+
+func TestBedrockError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-ErrorType", "ThrottlingException:http://internal.amazon.com/coral/com.amazon.bedrock/")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"message":"Too many requests, please wait before trying again."}`))
+	}))
+	defer ts.Close()
+
+	client := anthropic.NewClient("", anthropic.WithBedrock("us-east-1", testBedrockCredentials), anthropic.WithBaseURL(ts.URL))
+
+	_, err := client.CreateMessages(context.Background(), anthropic.MessagesRequest{
+		Model:     anthropic.ModelClaude3Haiku20240307,
+		Messages:  []anthropic.Message{anthropic.NewUserTextMessage("Hello")},
+		MaxTokens: 100,
+	})
+
+	var apiErr *anthropic.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an *APIError, got %v", err)
+	}
+	if !apiErr.IsRateLimitErr() || apiErr.Message != "Too many requests, please wait before trying again." {
+		t.Errorf("unexpected error: %+v", apiErr)
+	}
+	var reqErr *anthropic.Error
+	if !errors.As(err, &reqErr) || !reqErr.Retryable() {
+		t.Errorf("a throttling error should be retryable: %v", err)
+	}
+}
+
+func TestBedrockNotSupported(t *testing.T) {
+	client := anthropic.NewClient("", anthropic.WithBedrock("us-east-1", testBedrockCredentials))
+
+	_, err := client.ListModels(context.Background(), anthropic.ListParams{})
+	checks.ErrorIs(t, err, anthropic.ErrBedrockNotSupported, "ListModels should not be supported by Bedrock")
+
+	_, err = client.CountTokens(context.Background(), newTestMessagesRequest())
+	checks.ErrorIs(t, err, anthropic.ErrBedrockNotSupported, "CountTokens should not be supported by Bedrock")
+}
+
+func TestBedrockCredentialsError(t *testing.T) {
+	errNoCredentials := errors.New("no credentials")
+	client := anthropic.NewClient("", anthropic.WithBedrock("us-east-1", anthropic.AWSCredentialsProviderFunc(
+		func(ctx context.Context) (anthropic.AWSCredentials, error) {
+			return anthropic.AWSCredentials{}, errNoCredentials
+		},
+	)))
+
+	_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.ErrorIs(t, err, errNoCredentials, "the credentials error should be returned")
+}
+
+// writeBedrockEvents writes the events as the chunks of a Bedrock response stream.
+func writeBedrockEvents(t *testing.T, w io.Writer, events ...string) {
+	t.Helper()
+	for _, event := range events {
+		payload, err := json.Marshal(map[string]string{"bytes": base64.StdEncoding.EncodeToString([]byte(event))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeBedrockMessage(t, w, eventstream.Message{
+			Headers: []eventstream.Header{
+				{Name: ":event-type", Value: "chunk"},
+				{Name: ":content-type", Value: "application/json"},
+				{Name: ":message-type", Value: "event"},
+			},
+			Payload: payload,
+		})
+	}
+}
+
+func writeBedrockMessage(t *testing.T, w io.Writer, msg eventstream.Message) {
+	t.Helper()
+	data, err := eventstream.Encode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write(data)
+}
+
+func TestBedrockMessagesStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checkBedrockRequest(t, r, "/model/anthropic.claude-3-haiku-20240307-v1%3A0/invoke-with-response-stream")
+		if r.Header.Get("Accept") != "application/vnd.amazon.eventstream" {
+			t.Errorf("Accept = %q", r.Header.Get("Accept"))
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		writeBedrockEvents(t, w,
+			`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-3-haiku-20240307","usage":{"input_tokens":10,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" there"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`,
+			`{"type":"message_stop","amazon-bedrock-invocationMetrics":{"inputTokenCount":10,"outputTokenCount":5}}`,
+		)
+	}))
+	defer ts.Close()
+
+	client := anthropic.NewClient("", anthropic.WithBedrock("us-east-1", testBedrockCredentials), anthropic.WithBaseURL(ts.URL))
+
+	var received string
+	var stopped bool
+	resp, err := client.CreateMessagesStream(context.Background(), anthropic.MessagesStreamRequest{
+		MessagesRequest: anthropic.MessagesRequest{
+			Model:     anthropic.ModelClaude3Haiku20240307,
+			Messages:  []anthropic.Message{anthropic.NewUserTextMessage("Hello")},
+			MaxTokens: 100,
+		},
+		OnContentBlockDelta: func(data anthropic.MessagesEventContentBlockDeltaData) {
+			received += data.Delta.GetText()
+		},
+		OnMessageStop: func(anthropic.MessagesEventMessageStopData) {
+			stopped = true
+		},
+	})
+	checks.NoError(t, err, "CreateMessagesStream error")
+
+	if received != "Hello there" || !stopped {
+		t.Errorf("unexpected callbacks: received %q, stopped %v", received, stopped)
+	}
+	if resp.GetFirstContentText() != "Hello there" || resp.StopReason != anthropic.MessagesStopReasonEndTurn || resp.Usage.OutputTokens != 5 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestBedrockMessagesStreamException(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeBedrockEvents(t, w,
+			`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		)
+		writeBedrockMessage(t, w, eventstream.Message{
+			Headers: []eventstream.Header{
+				{Name: ":exception-type", Value: "modelStreamErrorException"},
+				{Name: ":content-type", Value: "application/json"},
+				{Name: ":message-type", Value: "exception"},
+			},
+			Payload: []byte(`{"message":"The model stopped unexpectedly."}`),
+		})
+	}))
+	defer ts.Close()
+
+	client := anthropic.NewClient("", anthropic.WithBedrock("us-east-1", testBedrockCredentials), anthropic.WithBaseURL(ts.URL))
+
+	stream, err := client.NewMessagesStream(context.Background(), anthropic.MessagesRequest{
+		Model:     anthropic.ModelClaude3Haiku20240307,
+		Messages:  []anthropic.Message{anthropic.NewUserTextMessage("Hello")},
+		MaxTokens: 100,
+	})
+	checks.NoError(t, err, "NewMessagesStream error")
+	defer stream.Close()
+
+	var events []anthropic.MessagesEvent
+	for stream.Next() {
+		events = append(events, stream.Event().Type)
+	}
+	if len(events) != 1 || events[0] != anthropic.MessagesEventMessageStart {
+		t.Errorf("unexpected events: %v", events)
+	}
+
+	var apiErr *anthropic.APIError
+	if !errors.As(stream.Err(), &apiErr) {
+		t.Fatalf("expected an *APIError, got %v", stream.Err())
+	}
+	if !apiErr.IsApiErr() || !strings.Contains(apiErr.Message, "stopped unexpectedly") {
+		t.Errorf("unexpected error: %+v", apiErr)
+	}
+}
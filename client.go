@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
 type Client struct {
@@ -27,7 +28,15 @@ func (h *httpHeader) Header() http.Header {
 	return http.Header(*h)
 }
 
+// GetRateLimitHeaders returns the rate limit headers of the response. Malformed headers are left zero,
+// use ParseRateLimitHeaders to know about them.
 func (h *httpHeader) GetRateLimitHeaders() RateLimitHeaders {
+	headers, _ := newRateLimitHeaders(h.Header())
+	return headers
+}
+
+// ParseRateLimitHeaders is like GetRateLimitHeaders, but also returns an error reporting the malformed headers.
+func (h *httpHeader) ParseRateLimitHeaders() (RateLimitHeaders, error) {
 	return newRateLimitHeaders(h.Header())
 }
 
@@ -39,18 +48,12 @@ func NewClient(apikey string, opts ...ClientOption) *Client {
 }
 
 func (c *Client) sendRequest(req *http.Request, v Response) error {
-	res, err := c.config.HTTPClient.Do(req)
+	res, err := c.doRequest(req, v, nil)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
 
-	v.SetHeader(res.Header)
-
-	if err := c.handlerRequestError(res); err != nil {
-		return err
-	}
-
 	if err = json.NewDecoder(res.Body).Decode(v); err != nil {
 		return err
 	}
@@ -58,59 +61,110 @@ func (c *Client) sendRequest(req *http.Request, v Response) error {
 	return nil
 }
 
-func (c *Client) handlerRequestError(resp *http.Response) error {
-	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return &RequestError{
-				StatusCode: resp.StatusCode,
-				Err:        err,
+// doRequest sends the request and checks the response status, retrying failed attempts
+// according to the client's RetryPolicy. Each attempt first waits for the client's RateLimiter.
+// If set, checkResponse reports a failure in a successful response, such as an error event
+// at the start of a stream: the attempt is retried if the policy allows, and the response
+// is returned as is otherwise. The caller must close the body of the returned response.
+func (c *Client) doRequest(req *http.Request, v Response, checkResponse func(*http.Response) error) (*http.Response, error) {
+	policy := c.config.RetryPolicy
+	limiter := c.config.RateLimiter
+	for attempt := 1; ; attempt++ {
+		if limiter != nil {
+			cost := rateLimitCostFromContext(req.Context())
+			if err := limiter.Wait(req.Context(), cost.inputTokens, cost.outputTokens); err != nil {
+				return nil, err
 			}
 		}
 
-		if c.IsVertexAI() && (resp.StatusCode == 401 || resp.StatusCode == 404) {
-			var errRes VertexAIErrorResponse
-			err := json.Unmarshal(bodyBytes, &errRes)
-			if err != nil {
-				// it could be an array
-				var errResArr []VertexAIErrorResponse
-				err = json.Unmarshal(bodyBytes, &errResArr)
-				if err == nil && len(errResArr) > 0 {
-					errRes = errResArr[0]
-				}
+		res, err := c.config.HTTPClient.Do(req)
+		if err == nil {
+			if limiter != nil {
+				limiter.update(res)
 			}
-
-			if err != nil || errRes.Error == nil {
-				reqErr := RequestError{
-					StatusCode: resp.StatusCode,
-					Err:        err,
-					RawBody:    bodyBytes,
+			v.SetHeader(res.Header)
+			if err = c.handlerRequestError(res); err == nil {
+				if checkResponse == nil {
+					return res, nil
 				}
-				return &reqErr
-			}
-			return fmt.Errorf("error, status code: %d, message: %w", resp.StatusCode, errRes.Error)
-		} else {
-			var errRes ErrorResponse
-			err := json.Unmarshal(bodyBytes, &errRes)
-			if err != nil || errRes.Error == nil {
-				reqErr := RequestError{
-					StatusCode: resp.StatusCode,
-					Err:        err,
-					RawBody:    bodyBytes,
+				if err = checkResponse(res); err == nil || !c.shouldRetry(req, attempt, err) {
+					return res, nil
 				}
-				return &reqErr
 			}
+			res.Body.Close()
+		}
+
+		if !c.shouldRetry(req, attempt, err) {
+			return nil, err
+		}
+
+		var header http.Header
+		if res != nil {
+			header = res.Header
+		}
+		if err := sleepContext(req.Context(), policy.delay(attempt, err, header)); err != nil {
+			return nil, err
+		}
 
-			return fmt.Errorf("error, status code: %d, message: %w", resp.StatusCode, errRes.Error)
+		if req, err = rewindRequest(req); err != nil {
+			return nil, err
 		}
 	}
-	return nil
+}
+
+// shouldRetry reports whether the failed attempt of req is retried.
+func (c *Client) shouldRetry(req *http.Request, attempt int, err error) bool {
+	policy := c.config.RetryPolicy
+	return policy != nil && policy.shouldRetry(attempt, err) && req.Context().Err() == nil
+}
+
+func (c *Client) handlerRequestError(resp *http.Response) error {
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return newError(resp, nil, &RequestError{
+			StatusCode: resp.StatusCode,
+			Err:        err,
+		})
+	}
+
+	return newError(resp, bodyBytes, c.parseErrorBody(resp.StatusCode, resp.Header, bodyBytes))
+}
+
+// parseErrorBody decodes the error returned in the body of a failed response.
+func (c *Client) parseErrorBody(statusCode int, header http.Header, bodyBytes []byte) error {
+	if c.IsBedrock() {
+		return parseBedrockError(statusCode, header.Get("X-Amzn-ErrorType"), bodyBytes)
+	}
+
+	if c.IsVertexAI() {
+		// Vertex AI errors are in the Google format, or in the Anthropic one for those passed through
+		if vertexErr := parseVertexErrorBody(bodyBytes); vertexErr != nil {
+			return vertexErr
+		}
+	}
+
+	var errRes ErrorResponse
+	err := json.Unmarshal(bodyBytes, &errRes)
+	if err != nil || errRes.Error == nil {
+		return &RequestError{
+			StatusCode: statusCode,
+			Err:        err,
+			RawBody:    bodyBytes,
+		}
+	}
+	return errRes.Error
 }
 
 func (c *Client) fullURL(suffix string, model string) string {
 	if isVertexAI(c.config.APIVersion) {
 		// replace the first slash with a colon
-		return fmt.Sprintf("%s/%s:%s", c.config.BaseURL, translateVertexModel(model), suffix[1:])
+		return fmt.Sprintf("%s/%s:%s", c.config.BaseURL, c.config.ModelRegistry.vertexID(model), suffix[1:])
+	} else if isBedrock(c.config.APIVersion) {
+		return fmt.Sprintf("%s/model/%s%s", c.config.BaseURL, bedrockModelPath(c.config.ModelRegistry.bedrockID(model, c.config.bedrockRegion)), suffix)
 	} else {
 		return fmt.Sprintf("%s%s", c.config.BaseURL, suffix)
 	}
@@ -118,21 +172,49 @@ func (c *Client) fullURL(suffix string, model string) string {
 
 type requestSetter func(req *http.Request)
 
+// withBetaVersion adds version to the anthropic-beta header, keeping the versions already set.
 func withBetaVersion(version string) requestSetter {
 	return func(req *http.Request) {
+		if existing := req.Header.Get("anthropic-beta"); existing != "" {
+			req.Header.Set("anthropic-beta", existing+","+version)
+			return
+		}
 		req.Header.Set("anthropic-beta", version)
 	}
 }
 
+// betaVersions returns the beta versions the setters add to the anthropic-beta header.
+func betaVersions(setters []requestSetter) []string {
+	req := &http.Request{Header: http.Header{}}
+	for _, setter := range setters {
+		setter(req)
+	}
+
+	var versions []string
+	for _, version := range strings.Split(req.Header.Get("anthropic-beta"), ",") {
+		if version = strings.TrimSpace(version); version != "" {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
 func (c *Client) newRequest(ctx context.Context, method, urlSuffix string, body any, requestSetters ...requestSetter) (req *http.Request, err error) {
 	// if the body implements the ModelGetter interface, use the model from the body
 	model := ""
-	if isVertexAI(c.config.APIVersion) && body != nil {
+	if isVertexAI(c.config.APIVersion) {
 		if vertexAISupport, ok := body.(VertexAISupport); ok {
 			model = vertexAISupport.GetModel()
 			vertexAISupport.SetAnthropicVersion(c.config.APIVersion)
 		} else {
-			return nil, fmt.Errorf("this call not supported by the Vertex AI API")
+			return nil, ErrVertexAINotSupported
+		}
+	} else if isBedrock(c.config.APIVersion) {
+		if bedrockSupport, ok := body.(VertexAISupport); ok {
+			model = bedrockSupport.GetModel()
+			bedrockSupport.SetAnthropicVersion(c.config.APIVersion)
+		} else {
+			return nil, ErrBedrockNotSupported
 		}
 	}
 
@@ -142,6 +224,15 @@ func (c *Client) newRequest(ctx context.Context, method, urlSuffix string, body
 		if err != nil {
 			return nil, err
 		}
+		if isBedrock(c.config.APIVersion) {
+			if reqBody, err = bedrockRequestBody(reqBody, betaVersions(requestSetters)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if c.config.RateLimiter != nil {
+		ctx = withRateLimitCost(ctx, body, reqBody)
 	}
 
 	req, err = http.NewRequestWithContext(ctx, method, c.fullURL(urlSuffix, model), bytes.NewBuffer(reqBody))
@@ -149,19 +240,33 @@ func (c *Client) newRequest(ctx context.Context, method, urlSuffix string, body
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("Accept", "application/json; charset=utf-8")
+	if isBedrock(c.config.APIVersion) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		// only the host and the X-Amz-* headers are signed, so the setters can still set headers
+		if err = c.signBedrockRequest(req, reqBody); err != nil {
+			return nil, err
+		}
+	} else {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		req.Header.Set("Accept", "application/json; charset=utf-8")
 
-	apiKey := c.config.apikey
-	if c.config.apiKeyFunc != nil {
-		apiKey = c.config.apiKeyFunc()
-	}
+		apiKey := c.config.apikey
+		if c.config.apiKeyFunc != nil {
+			apiKey = c.config.apiKeyFunc()
+		}
+		if isVertexAI(c.config.APIVersion) && c.config.vertexTokenSource != nil {
+			if apiKey, err = c.config.vertexTokenSource.token(ctx, c.config.HTTPClient); err != nil {
+				return nil, err
+			}
+		}
 
-	if isVertexAI(c.config.APIVersion) {
-		req.Header.Set("Authorization", "Bearer "+apiKey)
-	} else {
-		req.Header.Set("X-Api-Key", apiKey)
-		req.Header.Set("Anthropic-Version", c.config.APIVersion)
+		if isVertexAI(c.config.APIVersion) {
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+		} else {
+			req.Header.Set("X-Api-Key", apiKey)
+			req.Header.Set("Anthropic-Version", c.config.APIVersion)
+		}
 	}
 
 	for _, setter := range requestSetters {
@@ -178,7 +283,13 @@ func (c *Client) newStreamRequest(ctx context.Context, method, urlSuffix string,
 		return nil, err
 	}
 
-	req.Header.Set("Accept", "text/event-stream")
+	if c.IsBedrock() {
+		// the response is an AWS event stream, whose events hold the Anthropic events
+		req.Header.Set("Accept", "application/vnd.amazon.eventstream")
+		req.Header.Set("X-Amzn-Bedrock-Accept", "application/json")
+	} else {
+		req.Header.Set("Accept", "text/event-stream")
+	}
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Connection", "keep-alive")
 
@@ -188,3 +299,7 @@ func (c *Client) newStreamRequest(ctx context.Context, method, urlSuffix string,
 func (c *Client) IsVertexAI() bool {
 	return isVertexAI(c.config.APIVersion)
 }
+
+func (c *Client) IsBedrock() bool {
+	return isBedrock(c.config.APIVersion)
+}
@@ -8,24 +8,10 @@ const (
 	ModelClaude3Sonnet20240229  = "claude-3-sonnet-20240229"
 	ModelClaude3Haiku20240307   = "claude-3-haiku-20240307"
 	ModelClaude35Sonnet20240620 = "claude-3-5-sonnet-20240620"
+	ModelClaude37Sonnet20250219 = "claude-3-7-sonnet-20250219"
 )
 
 const (
 	RoleUser      = "user"
 	RoleAssistant = "assistant"
 )
-
-func translateVertexModel(model string) string {
-	switch model {
-	case ModelClaude3Haiku20240307:
-		return "claude-3-haiku@20240307"
-	case ModelClaude3Opus20240229:
-		return "claude-3-opus@20240229"
-	case ModelClaude3Sonnet20240229:
-		return "claude-3-sonnet@20240229"
-	case ModelClaude35Sonnet20240620:
-		return "claude-3-5-sonnet@20240620"
-	default:
-		return model
-	}
-}
@@ -1,13 +1,13 @@
 package anthropic
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
+
+	"github.com/liushuangls/go-anthropic/v2/internal/sse"
 )
 
 type CompleteEvent string
@@ -39,25 +39,16 @@ func (c *Client) CreateCompleteStream(ctx context.Context, request CompleteStrea
 		return
 	}
 
-	resp, err := c.config.HTTPClient.Do(req)
+	resp, err := c.doRequest(req, &response, nil)
 	if err != nil {
 		return
 	}
 	defer resp.Body.Close()
 
-	response.SetHeader(resp.Header)
-
-	if err := c.handlerRequestError(resp); err != nil {
-		return response, err
-	}
-
-	reader := bufio.NewReader(resp.Body)
-	var (
-		event             []byte
-		emptyMessageCount uint
-	)
+	decoder := sse.NewDecoder(resp.Body)
+	var emptyMessageCount uint
 	for {
-		rawLine, readErr := reader.ReadBytes('\n')
+		event, readErr := decoder.Next()
 		if readErr != nil {
 			if errors.Is(readErr, io.EOF) {
 				break
@@ -65,53 +56,40 @@ func (c *Client) CreateCompleteStream(ctx context.Context, request CompleteStrea
 			return response, readErr
 		}
 
-		noSpaceLine := bytes.TrimSpace(rawLine)
-		if len(noSpaceLine) == 0 {
-			continue
-		}
-		if bytes.HasPrefix(noSpaceLine, eventPrefix) {
-			event = bytes.TrimSpace(bytes.TrimPrefix(noSpaceLine, eventPrefix))
+		data := event.Data
+		switch CompleteEvent(event.Event) {
+		case CompleteEventError:
+			var d ErrorResponse
+			if err := json.Unmarshal(data, &d); err != nil {
+				return response, err
+			}
+			if request.OnError != nil {
+				request.OnError(d)
+			}
+			return response, newError(resp, data, d.Error)
+		case CompleteEventPing:
+			var d CompleteStreamPingData
+			if err := json.Unmarshal(data, &d); err != nil {
+				return response, err
+			}
+			if request.OnPing != nil {
+				request.OnPing(d)
+			}
 			continue
-		}
-		if bytes.HasPrefix(noSpaceLine, dataPrefix) {
-			var (
-				data      = bytes.TrimPrefix(noSpaceLine, dataPrefix)
-				eventType = CompleteEvent(event)
-			)
-			switch eventType {
-			case CompleteEventError:
-				var d ErrorResponse
-				if err := json.Unmarshal(data, &d); err != nil {
-					return response, err
-				}
-				if request.OnError != nil {
-					request.OnError(d)
-				}
-				return response, d.Error
-			case CompleteEventPing:
-				var d CompleteStreamPingData
-				if err := json.Unmarshal(data, &d); err != nil {
-					return response, err
-				}
-				if request.OnPing != nil {
-					request.OnPing(d)
-				}
-				continue
-			case CompleteEventCompletion:
-				var d CompleteResponse
-				if err := json.Unmarshal(data, &d); err != nil {
-					return response, err
-				}
-				if request.OnCompletion != nil {
-					request.OnCompletion(d)
-				}
-				response.Type = d.Type
-				response.ID = d.ID
-				response.StopReason = d.StopReason
-				response.Model = d.Model
-				response.Completion += d.Completion
-				continue
+		case CompleteEventCompletion:
+			var d CompleteResponse
+			if err := json.Unmarshal(data, &d); err != nil {
+				return response, err
+			}
+			if request.OnCompletion != nil {
+				request.OnCompletion(d)
 			}
+			response.Type = d.Type
+			response.ID = d.ID
+			response.StopReason = d.StopReason
+			response.Model = d.Model
+			response.Completion += d.Completion
+			continue
 		}
 		emptyMessageCount++
 		if emptyMessageCount > c.config.EmptyMessagesLimit {
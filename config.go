@@ -11,13 +11,18 @@ const (
 )
 
 const (
-	APIVersion20230601       = "2023-06-01"
-	APIVersionVertex20231016 = "vertex-2023-10-16"
+	APIVersion20230601        = "2023-06-01"
+	APIVersionVertex20231016  = "vertex-2023-10-16"
+	APIVersionBedrock20230531 = "bedrock-2023-05-31"
 )
 
 const (
-	BetaTools20240404 = "tools-2024-04-04"
-	BetaTools20240516 = "tools-2024-05-16"
+	BetaTools20240404          = "tools-2024-04-04"
+	BetaTools20240516          = "tools-2024-05-16"
+	BetaMessageBatches20240924 = "message-batches-2024-09-24"
+	BetaTokenCounting20241101  = "token-counting-2024-11-01"
+	BetaPromptCaching20240731  = "prompt-caching-2024-07-31"
+	BetaOutput128k20250219     = "output-128k-2025-02-19"
 )
 
 type ApiKeyFunc func() string
@@ -27,12 +32,27 @@ type ClientConfig struct {
 	apikey     string
 	apiKeyFunc ApiKeyFunc
 
+	vertexTokenSource *serviceAccountTokenSource
+
+	bedrockRegion      string
+	bedrockCredentials AWSCredentialsProvider
+
 	BaseURL     string
 	APIVersion  string
 	BetaVersion string
 	HTTPClient  *http.Client
 
 	EmptyMessagesLimit uint
+
+	// RetryPolicy configures automatic retries. Nil disables retries.
+	RetryPolicy *RetryPolicy
+
+	// RateLimiter delays the requests to stay within the rate limits. Nil disables it.
+	RateLimiter *RateLimiter
+
+	// ModelRegistry translates the model IDs for Vertex AI and Bedrock, and bounds MaxTokens.
+	// Nil disables both.
+	ModelRegistry *ModelRegistry
 }
 
 type ClientOption func(c *ClientConfig)
@@ -47,6 +67,7 @@ func newConfig(apikey string, opts ...ClientOption) ClientConfig {
 		HTTPClient:  &http.Client{},
 
 		EmptyMessagesLimit: defaultEmptyMessagesLimit,
+		ModelRegistry:      DefaultModelRegistry(),
 	}
 
 	for _, opt := range opts {
@@ -86,6 +107,20 @@ func WithBetaVersion(betaVersion string) ClientOption {
 	}
 }
 
+// WithRetryPolicy enables automatic retries of failed requests, see RetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *ClientConfig) {
+		c.RetryPolicy = &policy
+	}
+}
+
+// WithModelRegistry sets the registry of the models the client knows, DefaultModelRegistry by default.
+func WithModelRegistry(registry *ModelRegistry) ClientOption {
+	return func(c *ClientConfig) {
+		c.ModelRegistry = registry
+	}
+}
+
 func WithVertexAI(projectID string, location string) ClientOption {
 	return func(c *ClientConfig) {
 		c.BaseURL = fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/anthropic/models", location, projectID, location)
@@ -93,11 +128,27 @@ func WithVertexAI(projectID string, location string) ClientOption {
 	}
 }
 
+// WithBedrock sends the requests to Amazon Bedrock in the region, signed with the credentials
+// of the provider. Only the messages endpoints are supported, see https://docs.anthropic.com/en/api/claude-on-amazon-bedrock
+func WithBedrock(region string, credentialsProvider AWSCredentialsProvider) ClientOption {
+	return func(c *ClientConfig) {
+		c.BaseURL = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region)
+		c.APIVersion = APIVersionBedrock20230531
+		c.bedrockRegion = region
+		c.bedrockCredentials = credentialsProvider
+	}
+}
+
 func WithApiKeyFunc(apiKeyFunc ApiKeyFunc) ClientOption {
 	return func(c *ClientConfig) {
 		c.apiKeyFunc = apiKeyFunc
 	}
 }
+
 func isVertexAI(apiVersion string) bool {
 	return apiVersion == APIVersionVertex20231016
 }
+
+func isBedrock(apiVersion string) bool {
+	return apiVersion == APIVersionBedrock20230531
+}
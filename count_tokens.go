@@ -0,0 +1,43 @@
+package anthropic
+
+import (
+	"context"
+	"net/http"
+)
+
+// countTokensRequest holds the fields of a MessagesRequest that are accepted by the token counting endpoint.
+type countTokensRequest struct {
+	Model      string           `json:"model"`
+	Messages   []Message        `json:"messages"`
+	System     any              `json:"system,omitempty"`
+	Tools      []ToolDefinition `json:"tools,omitempty"`
+	ToolChoice *ToolChoice      `json:"tool_choice,omitempty"`
+	Thinking   *Thinking        `json:"thinking,omitempty"`
+}
+
+type CountTokensResponse struct {
+	httpHeader
+
+	InputTokens int `json:"input_tokens"`
+}
+
+// CountTokens counts the number of input tokens of a messages request, including system prompt and tools,
+// without creating a message. It is not supported by Vertex AI nor Bedrock.
+func (c *Client) CountTokens(ctx context.Context, request MessagesRequest) (response CountTokensResponse, err error) {
+	setters := append([]requestSetter{withBetaVersion(BetaTokenCounting20241101)}, c.messagesRequestSetters(&request)...)
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/messages/count_tokens", &countTokensRequest{
+		Model:      request.Model,
+		Messages:   request.Messages,
+		System:     request.system(),
+		Tools:      request.Tools,
+		ToolChoice: request.ToolChoice,
+		Thinking:   request.Thinking,
+	}, setters...)
+	if err != nil {
+		return
+	}
+
+	err = c.sendRequest(req, &response)
+	return
+}
@@ -0,0 +1,85 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/liushuangls/go-anthropic/v2/internal/test"
+	"github.com/liushuangls/go-anthropic/v2/internal/test/checks"
+	"github.com/liushuangls/go-anthropic/v2/jsonschema"
+)
+
+func TestCountTokens(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages/count_tokens", handleCountTokensEndpoint)
+
+	ts := server.AnthropicTestServer()
+	ts.Start()
+	defer ts.Close()
+
+	client := anthropic.NewClient(test.GetTestToken(), anthropic.WithBaseURL(ts.URL+"/v1"))
+
+	request := newTestMessagesRequest()
+	request.System = "You are a helpful assistant."
+	resp, err := client.CountTokens(context.Background(), request)
+	checks.NoError(t, err, "CountTokens error")
+	if resp.InputTokens != 14 {
+		t.Fatalf("unexpected input tokens: %d", resp.InputTokens)
+	}
+
+	request.Tools = []anthropic.ToolDefinition{
+		{
+			Name:        "get_weather",
+			Description: "Get the current weather in a given location",
+			InputSchema: jsonschema.Definition{Type: jsonschema.Object},
+		},
+	}
+	resp, err = client.CountTokens(context.Background(), request)
+	checks.NoError(t, err, "CountTokens with tools error")
+	if resp.InputTokens != 403 {
+		t.Fatalf("unexpected input tokens: %d", resp.InputTokens)
+	}
+}
+
+func TestCountTokensVertexAINotSupported(t *testing.T) {
+	client := anthropic.NewClient(test.GetTestToken(), anthropic.WithVertexAI("project", "us-east5"))
+
+	_, err := client.CountTokens(context.Background(), newTestMessagesRequest())
+	checks.ErrorIs(t, err, anthropic.ErrVertexAINotSupported, "should not be supported by Vertex AI")
+}
+
+func handleCountTokensEndpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.Contains(r.Header.Get("anthropic-beta"), anthropic.BetaTokenCounting20241101) {
+		http.Error(w, "missing beta header", http.StatusBadRequest)
+		return
+	}
+
+	var req map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "could not read request", http.StatusBadRequest)
+		return
+	}
+	if _, ok := req["max_tokens"]; ok {
+		http.Error(w, "max_tokens: Extra inputs are not permitted", http.StatusBadRequest)
+		return
+	}
+	if req["system"] != "You are a helpful assistant." {
+		http.Error(w, "system prompt not sent", http.StatusBadRequest)
+		return
+	}
+
+	inputTokens := 14
+	if _, ok := req["tools"]; ok {
+		inputTokens = 403
+	}
+	_, _ = fmt.Fprintf(w, `{"input_tokens":%d}`, inputTokens)
+}
@@ -3,6 +3,7 @@ package anthropic
 import (
 	"errors"
 	"fmt"
+	"net/http"
 )
 
 type ErrType string
@@ -26,6 +27,8 @@ const (
 
 var (
 	ErrSteamingNotSupportTools = errors.New("streaming is not yet supported tools")
+	ErrVertexAINotSupported    = errors.New("this call not supported by the Vertex AI API")
+	ErrBedrockNotSupported     = errors.New("this call not supported by the Bedrock API")
 )
 
 // APIError provides error information returned by the Anthropic API.
@@ -69,6 +72,82 @@ type RequestError struct {
 	RawBody    []byte
 }
 
+// Error is returned when a request fails with an error response. It wraps the *APIError or
+// *VertexAPIError decoded from the response, or a *RequestError if the body could not be decoded,
+// so errors.As can be used to get at the details.
+type Error struct {
+	StatusCode       int
+	RequestID        string
+	Header           http.Header
+	RateLimitHeaders RateLimitHeaders
+	RawBody          []byte
+	Err              error
+}
+
+func newError(resp *http.Response, rawBody []byte, err error) *Error {
+	// malformed rate limit headers are reported by ParseRateLimitHeaders
+	rateLimitHeaders, _ := newRateLimitHeaders(resp.Header)
+	return &Error{
+		StatusCode:       resp.StatusCode,
+		RequestID:        resp.Header.Get("request-id"),
+		Header:           resp.Header,
+		RateLimitHeaders: rateLimitHeaders,
+		RawBody:          rawBody,
+		Err:              err,
+	}
+}
+
+// ParseRateLimitHeaders parses the rate limit headers of the error response, and returns an error
+// reporting the malformed headers, like the method of the responses.
+func (e *Error) ParseRateLimitHeaders() (RateLimitHeaders, error) {
+	return newRateLimitHeaders(e.Header)
+}
+
+// Retryable reports whether the request may succeed if it is sent again.
+func (e *Error) Retryable() bool {
+	if errType, ok := errorType(e.Err); ok {
+		return isRetryableErrType(errType)
+	}
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// errorType returns the type of the *APIError or *VertexAPIError in err's chain.
+func errorType(err error) (ErrType, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Type, true
+	}
+	var vertexErr *VertexAPIError
+	if errors.As(err, &vertexErr) {
+		return vertexErr.Type(), true
+	}
+	return "", false
+}
+
+func isRetryableErrType(errType ErrType) bool {
+	return errType == ErrTypeRateLimit || errType == ErrTypeOverloaded || errType == ErrTypeApi
+}
+
+// errTypeFromStatusCode returns the error type the API uses for the HTTP status code.
+func errTypeFromStatusCode(statusCode int) ErrType {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return ErrTypeInvalidRequest
+	case http.StatusUnauthorized:
+		return ErrTypeAuthentication
+	case http.StatusForbidden:
+		return ErrTypePermission
+	case http.StatusNotFound:
+		return ErrTypeNotFound
+	case http.StatusTooManyRequests:
+		return ErrTypeRateLimit
+	case http.StatusServiceUnavailable, 529:
+		return ErrTypeOverloaded
+	default:
+		return ErrTypeApi
+	}
+}
+
 type ErrorResponse struct {
 	Type  string    `json:"type"`
 	Error *APIError `json:"error,omitempty"`
@@ -81,3 +160,14 @@ func (e *APIError) Error() string {
 func (e *RequestError) Error() string {
 	return fmt.Sprintf("anthropic request error status code: %d, err: %s", e.StatusCode, e.Err)
 }
+
+func (e *Error) Error() string {
+	if e.RequestID == "" {
+		return fmt.Sprintf("error, status code: %d, message: %s", e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("error, status code: %d, request id: %s, message: %s", e.StatusCode, e.RequestID, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,229 @@
+package anthropic_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/liushuangls/go-anthropic/v2/internal/test"
+	"github.com/liushuangls/go-anthropic/v2/internal/test/checks"
+)
+
+func TestErrorDetails(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range rateLimitHeaders {
+			w.Header().Set(k, v)
+		}
+		w.Header().Set("request-id", "req_123")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"Number of requests has exceeded your rate limit"}}`))
+	})
+
+	ts := server.AnthropicTestServer()
+	ts.Start()
+	defer ts.Close()
+
+	client := anthropic.NewClient(test.GetTestToken(), anthropic.WithBaseURL(ts.URL+"/v1"))
+	_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.HasError(t, err, "should error")
+
+	var e *anthropic.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *anthropic.Error, got %T", err)
+	}
+	if e.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status code: %d", e.StatusCode)
+	}
+	if e.RequestID != "req_123" {
+		t.Fatalf("unexpected request id: %q", e.RequestID)
+	}
+	if e.Header.Get("request-id") != "req_123" {
+		t.Fatalf("header not preserved: %v", e.Header)
+	}
+	if e.RateLimitHeaders.RequestsLimit != 100 {
+		t.Fatalf("unexpected rate limit headers: %+v", e.RateLimitHeaders)
+	}
+	if len(e.RawBody) == 0 {
+		t.Fatal("raw body not preserved")
+	}
+	if !e.Retryable() {
+		t.Fatal("rate limit error should be retryable")
+	}
+
+	var apiErr *anthropic.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsRateLimitErr() {
+		t.Fatalf("expected wrapped rate limit APIError, got %v", err)
+	}
+}
+
+func TestErrorUnparsableBody(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	})
+
+	ts := server.AnthropicTestServer()
+	ts.Start()
+	defer ts.Close()
+
+	client := anthropic.NewClient(test.GetTestToken(), anthropic.WithBaseURL(ts.URL+"/v1"))
+	_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.HasError(t, err, "should error")
+
+	var e *anthropic.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *anthropic.Error, got %T", err)
+	}
+	if !e.Retryable() {
+		t.Fatal("5xx error should be retryable")
+	}
+
+	var reqErr *anthropic.RequestError
+	if !errors.As(err, &reqErr) || reqErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected wrapped RequestError, got %v", err)
+	}
+}
+
+func TestErrorStreamEvent(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", handlerMessagesStream)
+
+	ts := server.AnthropicTestServer()
+	ts.Start()
+	defer ts.Close()
+
+	client := anthropic.NewClient(test.GetTestToken(), anthropic.WithBaseURL(ts.URL+"/v1"))
+	request := anthropic.MessagesStreamRequest{MessagesRequest: newTestMessagesRequest()}
+	request.SetTemperature(2)
+	_, err := client.CreateMessagesStream(context.Background(), request)
+	checks.HasError(t, err, "should error")
+
+	var e *anthropic.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *anthropic.Error, got %T", err)
+	}
+
+	var apiErr *anthropic.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsOverloadedErr() {
+		t.Fatalf("expected wrapped overloaded APIError, got %v", err)
+	}
+}
+
+func TestErrorVertexAI(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		wantType   anthropic.ErrType
+		wantVertex bool
+		retryable  bool
+	}{
+		{
+			name:       "invalid argument",
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":{"code":400,"message":"Invalid request","status":"INVALID_ARGUMENT"}}`,
+			wantType:   anthropic.ErrTypeInvalidRequest,
+			wantVertex: true,
+		},
+		{
+			name:       "permission denied",
+			statusCode: http.StatusForbidden,
+			body:       `{"error":{"code":403,"message":"Permission denied","status":"PERMISSION_DENIED"}}`,
+			wantType:   anthropic.ErrTypePermission,
+			wantVertex: true,
+		},
+		{
+			name:       "resource exhausted in an array",
+			statusCode: http.StatusTooManyRequests,
+			body:       `[{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}]`,
+			wantType:   anthropic.ErrTypeRateLimit,
+			wantVertex: true,
+			retryable:  true,
+		},
+		{
+			name:       "internal",
+			statusCode: http.StatusInternalServerError,
+			body:       `{"error":{"code":500,"message":"Internal error","status":"INTERNAL"}}`,
+			wantType:   anthropic.ErrTypeApi,
+			wantVertex: true,
+			retryable:  true,
+		},
+		{
+			name:       "unavailable",
+			statusCode: http.StatusServiceUnavailable,
+			body:       `{"error":{"code":503,"message":"The service is unavailable","status":"UNAVAILABLE"}}`,
+			wantType:   anthropic.ErrTypeOverloaded,
+			wantVertex: true,
+			retryable:  true,
+		},
+		{
+			name:       "unknown status",
+			statusCode: http.StatusNotFound,
+			body:       `{"error":{"code":404,"message":"Not found","status":"SOMETHING_NEW"}}`,
+			wantType:   anthropic.ErrTypeNotFound,
+			wantVertex: true,
+		},
+		{
+			name:       "anthropic format",
+			statusCode: 529,
+			body:       `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			wantType:   anthropic.ErrTypeOverloaded,
+			retryable:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+
+			client := anthropic.NewClient("token", anthropic.WithVertexAI("project", "us-east5"), anthropic.WithBaseURL(ts.URL))
+			_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+
+			var e *anthropic.Error
+			if !errors.As(err, &e) {
+				t.Fatalf("expected *anthropic.Error, got %v", err)
+			}
+			if e.Retryable() != tt.retryable || anthropic.IsRetryableError(err) != tt.retryable {
+				t.Errorf("Retryable = %v, want %v", e.Retryable(), tt.retryable)
+			}
+
+			var vertexErr *anthropic.VertexAPIError
+			if errors.As(err, &vertexErr) != tt.wantVertex {
+				t.Fatalf("unexpected error %T: %v", e.Err, err)
+			}
+			if !tt.wantVertex {
+				var apiErr *anthropic.APIError
+				if !errors.As(err, &apiErr) || apiErr.Type != tt.wantType {
+					t.Errorf("expected an APIError of type %s, got %v", tt.wantType, err)
+				}
+				return
+			}
+
+			if vertexErr.Type() != tt.wantType {
+				t.Errorf("Type = %s, want %s", vertexErr.Type(), tt.wantType)
+			}
+			predicates := map[anthropic.ErrType]bool{
+				anthropic.ErrTypeInvalidRequest: vertexErr.IsInvalidRequestErr(),
+				anthropic.ErrTypeAuthentication: vertexErr.IsAuthenticationErr(),
+				anthropic.ErrTypePermission:     vertexErr.IsPermissionErr(),
+				anthropic.ErrTypeNotFound:       vertexErr.IsNotFoundErr(),
+				anthropic.ErrTypeRateLimit:      vertexErr.IsRateLimitErr(),
+				anthropic.ErrTypeApi:            vertexErr.IsApiErr(),
+				anthropic.ErrTypeOverloaded:     vertexErr.IsOverloadedErr(),
+			}
+			for errType, ok := range predicates {
+				if ok != (errType == tt.wantType) {
+					t.Errorf("the %s predicate returned %v", errType, ok)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/liushuangls/go-anthropic/v2/jsonschema"
+)
+
+const (
+	defaultExtractToolName        = "extract"
+	defaultExtractToolDescription = "Record the requested data, matching the input schema exactly."
+)
+
+var ErrExtractNoToolUse = errors.New("the response has no call of the extract tool")
+
+type extractConfig struct {
+	toolName        string
+	toolDescription string
+	maxRetries      int
+}
+
+type ExtractOption func(*extractConfig)
+
+// WithExtractTool sets the name and description of the tool the data is extracted with.
+func WithExtractTool(name, description string) ExtractOption {
+	return func(c *extractConfig) {
+		c.toolName = name
+		c.toolDescription = description
+	}
+}
+
+// WithExtractMaxRetries sets how many times the model is asked to correct an output not matching
+// the schema, by sending back the validation errors. No retry is made by default.
+func WithExtractMaxRetries(n int) ExtractOption {
+	return func(c *extractConfig) {
+		c.maxRetries = n
+	}
+}
+
+// Extract makes the model return data of type T: it sends the request with a tool whose input schema
+// is generated from T, forcing its use with ToolChoice, then validates the tool input and decodes it.
+// The request must not set ToolChoice, and T must be a struct or a map.
+func Extract[T any](ctx context.Context, client *Client, request MessagesRequest, opts ...ExtractOption) (T, error) {
+	var result T
+
+	config := extractConfig{
+		toolName:        defaultExtractToolName,
+		toolDescription: defaultExtractToolDescription,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	schema, err := jsonschema.GenerateSchemaForType(reflect.TypeOf(&result).Elem())
+	if err != nil {
+		return result, err
+	}
+	if schema.Type != jsonschema.Object {
+		return result, fmt.Errorf("extracted type must be an object, got %s", schema.Type)
+	}
+
+	request.Tools = append(append([]ToolDefinition(nil), request.Tools...), ToolDefinition{
+		Name:        config.toolName,
+		Description: config.toolDescription,
+		InputSchema: *schema,
+	})
+	request.ToolChoice = &ToolChoice{Type: "tool", Name: config.toolName}
+	request.Messages = append([]Message(nil), request.Messages...)
+
+	for attempt := 0; ; attempt++ {
+		resp, err := client.CreateMessages(ctx, request)
+		if err != nil {
+			return result, err
+		}
+
+		toolUse := findToolUse(resp, config.toolName)
+		if toolUse == nil {
+			return result, ErrExtractNoToolUse
+		}
+
+		err = schema.Validate(toolUse.Input)
+		if err == nil {
+			if err = toolUse.UnmarshalInput(&result); err == nil {
+				return result, nil
+			}
+		}
+		if attempt >= config.maxRetries {
+			return result, fmt.Errorf("extracted data does not match the schema: %w", err)
+		}
+
+		request.Messages = append(request.Messages,
+			resp.ToMessage(),
+			NewToolResultsMessage(toolUse.ID, fmt.Sprintf("The input does not match the schema: %s. Call the tool again with corrected input.", err), true),
+		)
+	}
+}
+
+func findToolUse(resp MessagesResponse, name string) *MessageContentToolUse {
+	for _, content := range resp.Content {
+		if content.Type == MessagesContentTypeToolUse && content.MessageContentToolUse != nil && content.Name == name {
+			return content.MessageContentToolUse
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/liushuangls/go-anthropic/v2/internal/test/checks"
+	"github.com/liushuangls/go-anthropic/v2/jsonschema"
+)
+
+type testContact struct {
+	Name  string   `json:"name" jsonschema:"required"`
+	Email string   `json:"email" jsonschema:"format=email,required"`
+	Tags  []string `json:"tags"`
+}
+
+func TestExtract(t *testing.T) {
+	client := newMessagesTestClient(t, handleExtractEndpoint)
+
+	contact, err := anthropic.Extract[testContact](context.Background(), client, anthropic.MessagesRequest{
+		Model:     anthropic.ModelClaude3Haiku20240307,
+		MaxTokens: 1000,
+		Messages: []anthropic.Message{
+			anthropic.NewUserTextMessage("Contact John Doe at john@example.com about the invoice."),
+		},
+	}, anthropic.WithExtractTool("record_contact", "Record the contact details."), anthropic.WithExtractMaxRetries(1))
+	checks.NoError(t, err, "Extract error")
+	if contact.Name != "John Doe" || contact.Email != "john@example.com" || len(contact.Tags) != 1 {
+		t.Fatalf("unexpected contact: %+v", contact)
+	}
+}
+
+func TestExtractInvalid(t *testing.T) {
+	client := newMessagesTestClient(t, handleExtractEndpoint)
+
+	_, err := anthropic.Extract[testContact](context.Background(), client, anthropic.MessagesRequest{
+		Model:     anthropic.ModelClaude3Haiku20240307,
+		MaxTokens: 1000,
+		Messages: []anthropic.Message{
+			anthropic.NewUserTextMessage("Contact John Doe at john@example.com about the invoice."),
+		},
+	}, anthropic.WithExtractTool("record_contact", "Record the contact details."))
+
+	var errs jsonschema.ValidationErrors
+	if !errors.As(err, &errs) || errs[0].Error() != `$.email: value "john at example.com" is not a valid email` {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	_, err = anthropic.Extract[testContact](context.Background(), client, anthropic.MessagesRequest{
+		Model:     anthropic.ModelClaude3Haiku20240307,
+		MaxTokens: 1000,
+		Messages:  []anthropic.Message{anthropic.NewUserTextMessage("Hello")},
+	})
+	checks.ErrorIs(t, err, anthropic.ErrExtractNoToolUse, "should not find the extract tool call")
+}
+
+func handleExtractEndpoint(w http.ResponseWriter, r *http.Request) {
+	req, err := getMessagesRequest(r)
+	if err != nil {
+		http.Error(w, "could not read request", http.StatusBadRequest)
+		return
+	}
+	if len(req.Tools) != 1 || req.ToolChoice == nil || req.ToolChoice.Type != "tool" || req.ToolChoice.Name != req.Tools[0].Name {
+		http.Error(w, "extract tool not forced", http.StatusBadRequest)
+		return
+	}
+	if req.Tools[0].Name != "record_contact" {
+		writeToolUseResponse(w, anthropic.NewTextMessageContent("Hello!"))
+		return
+	}
+
+	input := `{"name":"John Doe","email":"john at example.com"}`
+	if len(req.Messages) == 3 {
+		feedback := req.Messages[2].Content[0]
+		if feedback.IsError == nil || !*feedback.IsError || !strings.Contains(feedback.Content[0].GetText(), "$.email") {
+			http.Error(w, "validation errors not sent back", http.StatusBadRequest)
+			return
+		}
+		input = `{"name":"John Doe","email":"john@example.com","tags":["invoice"]}`
+	}
+	writeToolUseResponse(w, anthropic.NewToolUseMessageContent("toolu_01", "record_contact", json.RawMessage(input)))
+}
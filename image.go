@@ -0,0 +1,192 @@
+package anthropic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register the GIF format for image.DecodeConfig
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+)
+
+const (
+	// MaxImageSize is the maximum size of the base64 encoding of an image accepted by the API.
+	MaxImageSize = 5 * 1024 * 1024
+	// MaxImageDimension is the maximum width and height of an image accepted by the API.
+	MaxImageDimension = 8000
+
+	imageJPEGQuality = 90
+	// maxImageDecodePixels bounds the images decoded to be downscaled, so a small file
+	// can't make the client allocate a huge image.
+	maxImageDecodePixels = 50_000_000
+)
+
+var (
+	ErrImageUnsupportedMediaType = errors.New("unsupported image media type")
+	ErrImageTooLarge             = errors.New("image exceeds the API size limits")
+)
+
+var supportedImageMediaTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+type imageConfig struct {
+	maxDimension int
+}
+
+type ImageOption func(*imageConfig)
+
+// WithImageDownscale downscales JPEG and PNG images whose width or height exceeds maxDimension,
+// or whose size exceeds MaxImageSize, keeping their aspect ratio. Anthropic recommends at most
+// 1568 pixels, larger images being downscaled by the API anyway.
+func WithImageDownscale(maxDimension int) ImageOption {
+	return func(c *imageConfig) {
+		c.maxDimension = maxDimension
+	}
+}
+
+// NewImageContentFromBytes returns a base64 image block with the media type detected from the data.
+// JPEG, PNG, GIF and WebP images are supported, within MaxImageSize once base64 encoded and MaxImageDimension.
+func NewImageContentFromBytes(data []byte, opts ...ImageOption) (MessageContent, error) {
+	var config imageConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	mediaType := http.DetectContentType(data)
+	if !supportedImageMediaTypes[mediaType] {
+		return MessageContent{}, fmt.Errorf("%w: %s", ErrImageUnsupportedMediaType, mediaType)
+	}
+
+	// the standard library can't decode WebP images, so their dimensions are left to the API
+	if mediaType != "image/webp" {
+		imgConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return MessageContent{}, fmt.Errorf("decode image: %w", err)
+		}
+
+		width, height := imgConfig.Width, imgConfig.Height
+		canDownscale := config.maxDimension > 0 && (mediaType == "image/jpeg" || mediaType == "image/png")
+		if canDownscale && (max(width, height) > config.maxDimension || encodedImageSize(len(data)) > MaxImageSize) {
+			if width*height > maxImageDecodePixels {
+				return MessageContent{}, fmt.Errorf("%w: %dx%d pixels, too large to downscale", ErrImageTooLarge, width, height)
+			}
+			data, width, height, err = downscaleImage(data, mediaType, config.maxDimension)
+			if err != nil {
+				return MessageContent{}, err
+			}
+		}
+		if width > MaxImageDimension || height > MaxImageDimension {
+			return MessageContent{}, fmt.Errorf("%w: %dx%d pixels, the maximum is %d", ErrImageTooLarge, width, height, MaxImageDimension)
+		}
+	}
+	if size := encodedImageSize(len(data)); size > MaxImageSize {
+		return MessageContent{}, fmt.Errorf("%w: %d bytes once base64 encoded, the maximum is %d", ErrImageTooLarge, size, MaxImageSize)
+	}
+
+	return NewImageMessageContent(MessageContentSource{
+		Type:      MessagesContentSourceTypeBase64,
+		MediaType: mediaType,
+		Data:      base64.StdEncoding.EncodeToString(data),
+	}), nil
+}
+
+// NewImageContentFromReader reads the image and returns it like NewImageContentFromBytes.
+func NewImageContentFromReader(r io.Reader, opts ...ImageOption) (MessageContent, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return MessageContent{}, err
+	}
+	return NewImageContentFromBytes(data, opts...)
+}
+
+// NewImageContentFromFile reads the image file and returns it like NewImageContentFromBytes.
+func NewImageContentFromFile(path string, opts ...ImageOption) (MessageContent, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return MessageContent{}, err
+	}
+	return NewImageContentFromBytes(data, opts...)
+}
+
+// NewImageContentFromURL returns an image block the API fetches from the URL.
+func NewImageContentFromURL(url string) MessageContent {
+	return NewImageMessageContent(NewURLSource(url))
+}
+
+// encodedImageSize returns the size of the base64 encoding of an image of n bytes, which the API limits.
+func encodedImageSize(n int) int {
+	return base64.StdEncoding.EncodedLen(n)
+}
+
+// downscaleImage fits the image in maxDimension pixels, halving it further while its encoding
+// exceeds MaxImageSize, and returns it encoded in its original format.
+func downscaleImage(data []byte, mediaType string, maxDimension int) ([]byte, int, int, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("decode image: %w", err)
+	}
+
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	if longest := max(width, height); longest > maxDimension {
+		width = max(width*maxDimension/longest, 1)
+		height = max(height*maxDimension/longest, 1)
+	}
+
+	for {
+		dst := resizeImage(src, width, height)
+
+		var buf bytes.Buffer
+		if mediaType == "image/png" {
+			err = png.Encode(&buf, dst)
+		} else {
+			err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: imageJPEGQuality})
+		}
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("encode image: %w", err)
+		}
+
+		if encodedImageSize(buf.Len()) <= MaxImageSize || (width == 1 && height == 1) {
+			return buf.Bytes(), width, height, nil
+		}
+		width, height = max(width/2, 1), max(height/2, 1)
+	}
+}
+
+// resizeImage downscales the image to width x height pixels, averaging the source pixels
+// covered by each destination pixel. The result has 8 bits per channel, so a PNG is not encoded
+// with 16 bits per channel, bigger than its source.
+func resizeImage(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcHeight/height
+		y1 := max(bounds.Min.Y+(y+1)*srcHeight/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcWidth/width
+			x1 := max(bounds.Min.X+(x+1)*srcWidth/width, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(b / n >> 8), A: uint8(a / n >> 8)})
+		}
+	}
+	return dst
+}
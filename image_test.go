@@ -0,0 +1,126 @@
+package anthropic_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/liushuangls/go-anthropic/v2/internal/test/checks"
+)
+
+func newTestPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodeImageContent(t *testing.T, content anthropic.MessageContent) image.Config {
+	t.Helper()
+
+	data, err := base64.StdEncoding.DecodeString(content.Source.Data.(string))
+	checks.NoError(t, err, "invalid base64 data")
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	checks.NoError(t, err, "invalid image data")
+	return config
+}
+
+func TestNewImageContentFromFile(t *testing.T) {
+	content, err := anthropic.NewImageContentFromFile("internal/test/sources/ant.jpg")
+	checks.NoError(t, err, "NewImageContentFromFile error")
+	if content.Type != anthropic.MessagesContentTypeImage || content.Source.Type != anthropic.MessagesContentSourceTypeBase64 ||
+		content.Source.MediaType != "image/jpeg" {
+		t.Fatalf("unexpected content: %+v", content.Source)
+	}
+	decodeImageContent(t, content)
+
+	_, err = anthropic.NewImageContentFromFile("internal/test/sources/missing.jpg")
+	checks.HasError(t, err, "should fail to read a missing file")
+}
+
+func TestNewImageContentFromBytes(t *testing.T) {
+	content, err := anthropic.NewImageContentFromReader(bytes.NewReader(newTestPNG(t, 100, 50)))
+	checks.NoError(t, err, "NewImageContentFromReader error")
+	if content.Source.MediaType != "image/png" {
+		t.Fatalf("unexpected media type: %s", content.Source.MediaType)
+	}
+	if config := decodeImageContent(t, content); config.Width != 100 || config.Height != 50 {
+		t.Fatalf("unexpected dimensions: %dx%d", config.Width, config.Height)
+	}
+
+	_, err = anthropic.NewImageContentFromBytes([]byte("%PDF-1.4 not an image"))
+	checks.ErrorIs(t, err, anthropic.ErrImageUnsupportedMediaType, "should reject documents")
+
+	_, err = anthropic.NewImageContentFromBytes(newTestPNG(t, anthropic.MaxImageDimension+1, 1))
+	checks.ErrorIs(t, err, anthropic.ErrImageTooLarge, "should reject images wider than the maximum")
+}
+
+func TestNewImageContentDownscale(t *testing.T) {
+	content, err := anthropic.NewImageContentFromBytes(newTestPNG(t, 400, 100), anthropic.WithImageDownscale(200))
+	checks.NoError(t, err, "NewImageContentFromBytes error")
+	if config := decodeImageContent(t, content); config.Width != 200 || config.Height != 50 {
+		t.Fatalf("unexpected dimensions: %dx%d", config.Width, config.Height)
+	}
+
+	content, err = anthropic.NewImageContentFromFile("internal/test/sources/ant.jpg", anthropic.WithImageDownscale(64))
+	checks.NoError(t, err, "NewImageContentFromFile error")
+	if config := decodeImageContent(t, content); max(config.Width, config.Height) != 64 || content.Source.MediaType != "image/jpeg" {
+		t.Fatalf("unexpected image: %dx%d %s", config.Width, config.Height, content.Source.MediaType)
+	}
+
+	_, err = anthropic.NewImageContentFromBytes(newTestPNG(t, anthropic.MaxImageDimension+1, 1), anthropic.WithImageDownscale(1568))
+	if errors.Is(err, anthropic.ErrImageTooLarge) {
+		t.Fatalf("image should have been downscaled: %v", err)
+	}
+}
+
+func TestNewImageContentDownscalePNG(t *testing.T) {
+	src := newTestPNG(t, 400, 100)
+	content, err := anthropic.NewImageContentFromBytes(src, anthropic.WithImageDownscale(200))
+	checks.NoError(t, err, "NewImageContentFromBytes error")
+
+	data, err := base64.StdEncoding.DecodeString(content.Source.Data.(string))
+	checks.NoError(t, err, "invalid base64 data")
+	// the bit depth is the first byte after the width and height of the IHDR chunk
+	if depth := data[24]; depth != 8 {
+		t.Errorf("downscaled PNG bit depth = %d, want 8", depth)
+	}
+	if len(data) > len(src) {
+		t.Errorf("downscaled PNG is larger than its source: %d > %d bytes", len(data), len(src))
+	}
+}
+
+func TestNewImageContentDecodeLimit(t *testing.T) {
+	// a small PNG claiming huge dimensions is rejected before being decoded
+	data := newTestPNG(t, 1, 1)
+	binary.BigEndian.PutUint32(data[16:], 20000)
+	binary.BigEndian.PutUint32(data[20:], 20000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	_, err := anthropic.NewImageContentFromBytes(data, anthropic.WithImageDownscale(1568))
+	checks.ErrorIs(t, err, anthropic.ErrImageTooLarge, "should reject images too large to decode")
+}
+
+func TestNewImageContentFromURL(t *testing.T) {
+	content := anthropic.NewImageContentFromURL("https://example.com/image.jpg")
+	if content.Source.Type != anthropic.MessagesContentSourceTypeURL || !strings.HasSuffix(content.Source.URL, "image.jpg") {
+		t.Fatalf("unexpected source: %+v", content.Source)
+	}
+}
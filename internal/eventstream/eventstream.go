@@ -0,0 +1,255 @@
+// Package eventstream decodes the binary AWS event stream encoding, used by the streaming
+// AWS APIs such as Bedrock's InvokeModelWithResponseStream. A message is framed as:
+//
+//	total length (4) | headers length (4) | prelude CRC (4) | headers | payload | message CRC (4)
+//
+// all integers being big-endian and the CRCs CRC-32 (IEEE) checksums.
+package eventstream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+const (
+	preludeLen = 12
+	crcLen     = 4
+
+	// MaxMessageLen bounds the length of a message, so a corrupted prelude
+	// can not make the decoder allocate an arbitrary amount of memory.
+	MaxMessageLen = 24 * 1024 * 1024
+)
+
+// header value types
+const (
+	typeTrue byte = iota
+	typeFalse
+	typeByte
+	typeInt16
+	typeInt32
+	typeInt64
+	typeBytes
+	typeString
+	typeTimestamp
+	typeUUID
+)
+
+// Header is a message header. Value is a bool, int8, int16, int32, int64, []byte, string,
+// time.Time or [16]byte (a UUID).
+type Header struct {
+	Name  string
+	Value any
+}
+
+// Message is a decoded event stream message.
+type Message struct {
+	Headers []Header
+	Payload []byte
+}
+
+// Header returns the value of the named header if it is a string, or an empty string.
+func (m Message) Header(name string) string {
+	for _, h := range m.Headers {
+		if h.Name == name {
+			s, _ := h.Value.(string)
+			return s
+		}
+	}
+	return ""
+}
+
+// Decoder reads messages from a stream.
+type Decoder struct {
+	r io.Reader
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Next returns the next message. It returns io.EOF at the end of the stream,
+// and io.ErrUnexpectedEOF if the stream ends within a message.
+func (d *Decoder) Next() (Message, error) {
+	prelude := make([]byte, preludeLen)
+	if _, err := io.ReadFull(d.r, prelude); err != nil {
+		return Message{}, err
+	}
+
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc := crc32.ChecksumIEEE(prelude[:8]); crc != binary.BigEndian.Uint32(prelude[8:12]) {
+		return Message{}, fmt.Errorf("eventstream: prelude checksum mismatch")
+	}
+	if totalLen < preludeLen+crcLen || totalLen > MaxMessageLen {
+		return Message{}, fmt.Errorf("eventstream: invalid message length %d", totalLen)
+	}
+	if headersLen > totalLen-preludeLen-crcLen {
+		return Message{}, fmt.Errorf("eventstream: invalid headers length %d", headersLen)
+	}
+
+	msg := make([]byte, totalLen)
+	copy(msg, prelude)
+	if _, err := io.ReadFull(d.r, msg[preludeLen:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Message{}, err
+	}
+
+	end := totalLen - crcLen
+	if crc := crc32.ChecksumIEEE(msg[:end]); crc != binary.BigEndian.Uint32(msg[end:]) {
+		return Message{}, fmt.Errorf("eventstream: message checksum mismatch")
+	}
+
+	headers, err := decodeHeaders(msg[preludeLen : preludeLen+headersLen])
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Headers: headers,
+		Payload: msg[preludeLen+headersLen : end],
+	}, nil
+}
+
+func decodeHeaders(data []byte) ([]Header, error) {
+	var headers []Header
+	for len(data) > 0 {
+		nameLen := int(data[0])
+		if len(data) < 1+nameLen+1 {
+			return nil, errors.New("eventstream: truncated header")
+		}
+		name := string(data[1 : 1+nameLen])
+		valueType := data[1+nameLen]
+		data = data[2+nameLen:]
+
+		value, n, err := decodeHeaderValue(valueType, data)
+		if err != nil {
+			return nil, fmt.Errorf("eventstream: header %q: %w", name, err)
+		}
+		headers = append(headers, Header{Name: name, Value: value})
+		data = data[n:]
+	}
+	return headers, nil
+}
+
+// decodeHeaderValue decodes a value of the type and returns the number of bytes it takes.
+func decodeHeaderValue(valueType byte, data []byte) (any, int, error) {
+	fixedLen := map[byte]int{typeByte: 1, typeInt16: 2, typeInt32: 4, typeInt64: 8, typeTimestamp: 8, typeUUID: 16}
+	if n, ok := fixedLen[valueType]; ok && len(data) < n {
+		return nil, 0, errors.New("truncated value")
+	}
+
+	switch valueType {
+	case typeTrue:
+		return true, 0, nil
+	case typeFalse:
+		return false, 0, nil
+	case typeByte:
+		return int8(data[0]), 1, nil
+	case typeInt16:
+		return int16(binary.BigEndian.Uint16(data)), 2, nil
+	case typeInt32:
+		return int32(binary.BigEndian.Uint32(data)), 4, nil
+	case typeInt64:
+		return int64(binary.BigEndian.Uint64(data)), 8, nil
+	case typeTimestamp:
+		return time.UnixMilli(int64(binary.BigEndian.Uint64(data))).UTC(), 8, nil
+	case typeUUID:
+		var uuid [16]byte
+		copy(uuid[:], data)
+		return uuid, 16, nil
+	case typeBytes, typeString:
+		if len(data) < 2 {
+			return nil, 0, errors.New("truncated value")
+		}
+		n := int(binary.BigEndian.Uint16(data))
+		if len(data) < 2+n {
+			return nil, 0, errors.New("truncated value")
+		}
+		if valueType == typeString {
+			return string(data[2 : 2+n]), 2 + n, nil
+		}
+		return bytes.Clone(data[2 : 2+n]), 2 + n, nil
+	default:
+		return nil, 0, fmt.Errorf("unknown value type %d", valueType)
+	}
+}
+
+// Encode returns the encoding of the message.
+func Encode(msg Message) ([]byte, error) {
+	var headers bytes.Buffer
+	for _, h := range msg.Headers {
+		if len(h.Name) > 255 {
+			return nil, fmt.Errorf("eventstream: header name %q is too long", h.Name)
+		}
+		headers.WriteByte(byte(len(h.Name)))
+		headers.WriteString(h.Name)
+		if err := encodeHeaderValue(&headers, h.Value); err != nil {
+			return nil, fmt.Errorf("eventstream: header %q: %w", h.Name, err)
+		}
+	}
+
+	totalLen := preludeLen + headers.Len() + len(msg.Payload) + crcLen
+	if totalLen > MaxMessageLen {
+		return nil, fmt.Errorf("eventstream: message length %d exceeds the maximum", totalLen)
+	}
+
+	buf := make([]byte, 0, totalLen)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(totalLen))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(headers.Len()))
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+	buf = append(buf, headers.Bytes()...)
+	buf = append(buf, msg.Payload...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+	return buf, nil
+}
+
+func encodeHeaderValue(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			buf.WriteByte(typeTrue)
+		} else {
+			buf.WriteByte(typeFalse)
+		}
+	case int8:
+		buf.Write([]byte{typeByte, byte(v)})
+	case int16:
+		buf.WriteByte(typeInt16)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(v)))
+	case int32:
+		buf.WriteByte(typeInt32)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(v)))
+	case int64:
+		buf.WriteByte(typeInt64)
+		buf.Write(binary.BigEndian.AppendUint64(nil, uint64(v)))
+	case time.Time:
+		buf.WriteByte(typeTimestamp)
+		buf.Write(binary.BigEndian.AppendUint64(nil, uint64(v.UnixMilli())))
+	case [16]byte:
+		buf.WriteByte(typeUUID)
+		buf.Write(v[:])
+	case []byte:
+		return encodeVariableValue(buf, typeBytes, v)
+	case string:
+		return encodeVariableValue(buf, typeString, []byte(v))
+	default:
+		return fmt.Errorf("unsupported value type %T", value)
+	}
+	return nil
+}
+
+func encodeVariableValue(buf *bytes.Buffer, valueType byte, data []byte) error {
+	if len(data) > 0xffff {
+		return errors.New("value is too long")
+	}
+	buf.WriteByte(valueType)
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(data))))
+	buf.Write(data)
+	return nil
+}
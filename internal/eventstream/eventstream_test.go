@@ -0,0 +1,130 @@
+package eventstream_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2/internal/eventstream"
+)
+
+func mustEncode(t *testing.T, msg eventstream.Message) []byte {
+	t.Helper()
+	data, err := eventstream.Encode(msg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	return data
+}
+
+func TestDecodeEmptyMessage(t *testing.T) {
+	// the empty_message vector of the AWS event stream test suite
+	data := []byte{0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x00, 0x05, 0xc2, 0x48, 0xeb, 0x7d, 0x98, 0xc8, 0xff}
+
+	msg, err := eventstream.NewDecoder(bytes.NewReader(data)).Next()
+	if err != nil {
+		t.Fatalf("Next error: %v", err)
+	}
+	if len(msg.Headers) != 0 || len(msg.Payload) != 0 {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	if encoded := mustEncode(t, eventstream.Message{}); !bytes.Equal(encoded, data) {
+		t.Errorf("Encode = %x, want %x", encoded, data)
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	messages := []eventstream.Message{
+		{
+			Headers: []eventstream.Header{
+				{Name: ":event-type", Value: "chunk"},
+				{Name: ":content-type", Value: "application/json"},
+				{Name: ":message-type", Value: "event"},
+			},
+			Payload: []byte(`{"bytes":"eyJ0eXBlIjoicGluZyJ9"}`),
+		},
+		{
+			Headers: []eventstream.Header{
+				{Name: "true", Value: true},
+				{Name: "false", Value: false},
+				{Name: "byte", Value: int8(-1)},
+				{Name: "int16", Value: int16(-300)},
+				{Name: "int32", Value: int32(1 << 20)},
+				{Name: "int64", Value: int64(-1 << 40)},
+				{Name: "bytes", Value: []byte{0, 1, 2}},
+				{Name: "timestamp", Value: time.UnixMilli(1700000000123).UTC()},
+				{Name: "uuid", Value: [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}},
+			},
+			Payload: []byte("payload"),
+		},
+	}
+
+	var stream []byte
+	for _, msg := range messages {
+		stream = append(stream, mustEncode(t, msg)...)
+	}
+
+	// read one byte at a time to check messages are not assumed to arrive in a single read
+	d := eventstream.NewDecoder(iotest.OneByteReader(bytes.NewReader(stream)))
+	for i, want := range messages {
+		got, err := d.Next()
+		if err != nil {
+			t.Fatalf("Next %d error: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("message %d = %+v, want %+v", i, got, want)
+		}
+	}
+	if _, err := d.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next at the end = %v, want io.EOF", err)
+	}
+
+	if got := messages[0].Header(":event-type"); got != "chunk" {
+		t.Errorf("Header = %q", got)
+	}
+	if got := messages[1].Header("byte"); got != "" {
+		t.Errorf("Header of a non-string value = %q", got)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	valid := mustEncode(t, eventstream.Message{
+		Headers: []eventstream.Header{{Name: ":message-type", Value: "event"}},
+		Payload: []byte("data"),
+	})
+
+	corrupt := func(i int) []byte {
+		data := bytes.Clone(valid)
+		data[i] ^= 0xff
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "truncated prelude", data: valid[:5], want: io.ErrUnexpectedEOF},
+		{name: "truncated message", data: valid[:len(valid)-1], want: io.ErrUnexpectedEOF},
+		{name: "prelude checksum", data: corrupt(9)},
+		{name: "total length", data: corrupt(0)},
+		{name: "message checksum", data: corrupt(len(valid) - 1)},
+		{name: "payload", data: corrupt(len(valid) - 5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := eventstream.NewDecoder(bytes.NewReader(tt.data)).Next()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
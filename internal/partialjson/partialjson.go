@@ -0,0 +1,404 @@
+// Package partialjson parses the prefix of a JSON document into a best-effort value,
+// as the tool inputs streamed in input_json_delta fragments.
+package partialjson
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var errIncomplete = errors.New("incomplete value")
+
+// Parse returns the value of the JSON prefix data, as encoding/json decodes into an any.
+// Unterminated objects and arrays hold the members parsed so far, and an unterminated string
+// its characters so far. Members whose value has not started or is an unterminated number
+// or literal are left out, since their value is not known yet. Parse returns nil for empty data,
+// and an error if data is not the prefix of a JSON document.
+func Parse(data []byte) (any, error) {
+	var p Parser
+	if err := p.Write(data); err != nil {
+		return nil, err
+	}
+	return p.Value(), nil
+}
+
+// Parser parses a JSON document written in fragments. Each fragment is parsed once, and
+// Value returns the value of the data written so far as Parse does, for the cost of copying
+// the unterminated objects and arrays. A Parser must not be copied after the first Write.
+type Parser struct {
+	state state
+	// stack holds the unterminated objects and arrays, the innermost last
+	stack []*container
+	// str holds the characters of the string being parsed, key tells whether it is an object key
+	str strings.Builder
+	key bool
+	// pending holds the number, literal, escape sequence or UTF-8 character being parsed
+	pending      []byte
+	literal      string
+	literalValue any
+
+	root   any
+	done   bool
+	offset int
+	err    error
+}
+
+type state int
+
+const (
+	stateValue state = iota
+	stateArrayStart
+	stateObjectStart
+	stateKey
+	stateColon
+	stateAfterValue
+	stateString
+	stateNumber
+	stateLiteral
+)
+
+// container is an unterminated object or array, with the members parsed so far.
+type container struct {
+	object  map[string]any
+	array   []any
+	isArray bool
+	// key is the key of the object member being parsed
+	key string
+}
+
+// Write parses the next fragment of the document. Once it has returned an error,
+// the following calls return it too.
+func (p *Parser) Write(data []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	for _, c := range data {
+		if err := p.consume(c); err != nil {
+			p.err = err
+			return err
+		}
+		p.offset++
+	}
+	return nil
+}
+
+// Value returns the value of the data written so far, nil if it is invalid. The values it returns
+// share the members terminated before, and must not be modified.
+func (p *Parser) Value() any {
+	if p.err != nil {
+		return nil
+	}
+	if p.done {
+		return p.root
+	}
+
+	var value any
+	if p.state == stateString && !p.key {
+		value = p.str.String()
+	}
+	// a value is only added to an object once its key is known
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		c := p.stack[i]
+		if c.isArray {
+			array := slices.Clip(c.array)
+			if value != nil {
+				array = append(array, value)
+			}
+			value = array
+		} else {
+			object := maps.Clone(c.object)
+			if value != nil {
+				object[c.key] = value
+			}
+			value = object
+		}
+	}
+	return value
+}
+
+func (p *Parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("partialjson: offset %d: %s", p.offset, fmt.Sprintf(format, args...))
+}
+
+func (p *Parser) consume(c byte) error {
+	switch p.state {
+	case stateString:
+		return p.consumeString(c)
+	case stateNumber:
+		// a number ends with the first byte that is not part of it
+		if c >= '0' && c <= '9' || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E' {
+			p.pending = append(p.pending, c)
+			return nil
+		}
+		f, err := strconv.ParseFloat(string(p.pending), 64)
+		if err != nil {
+			return p.errorf("invalid number %q", p.pending)
+		}
+		p.pending = p.pending[:0]
+		p.complete(f)
+		return p.consume(c)
+	case stateLiteral:
+		if c != p.literal[len(p.pending)] {
+			return p.errorf("invalid literal, expected %q", p.literal)
+		}
+		p.pending = append(p.pending, c)
+		if len(p.pending) == len(p.literal) {
+			p.pending = p.pending[:0]
+			p.complete(p.literalValue)
+		}
+		return nil
+	}
+
+	switch c {
+	case ' ', '\t', '\n', '\r':
+		return nil
+	}
+
+	switch p.state {
+	case stateValue, stateArrayStart:
+		if p.state == stateArrayStart && c == ']' {
+			p.closeContainer()
+			return nil
+		}
+		return p.startValue(c)
+	case stateObjectStart, stateKey:
+		if p.state == stateObjectStart && c == '}' {
+			p.closeContainer()
+			return nil
+		}
+		if c != '"' {
+			return p.errorf("expected object key")
+		}
+		p.state, p.key = stateString, true
+		return nil
+	case stateColon:
+		if c != ':' {
+			return p.errorf("expected ':' after object key")
+		}
+		p.state = stateValue
+		return nil
+	default: // stateAfterValue
+		if len(p.stack) == 0 {
+			return p.errorf("unexpected data after top-level value")
+		}
+		top := p.stack[len(p.stack)-1]
+		switch {
+		case c == ',' && top.isArray:
+			p.state = stateValue
+		case c == ',':
+			p.state = stateKey
+		case c == ']' && top.isArray, c == '}' && !top.isArray:
+			p.closeContainer()
+		case top.isArray:
+			return p.errorf("expected ',' or ']' after array value")
+		default:
+			return p.errorf("expected ',' or '}' after object value")
+		}
+		return nil
+	}
+}
+
+func (p *Parser) startValue(c byte) error {
+	switch {
+	case c == '{':
+		p.stack = append(p.stack, &container{object: map[string]any{}})
+		p.state = stateObjectStart
+	case c == '[':
+		p.stack = append(p.stack, &container{array: []any{}, isArray: true})
+		p.state = stateArrayStart
+	case c == '"':
+		p.state, p.key = stateString, false
+	case c == 't':
+		p.startLiteral("true", true)
+	case c == 'f':
+		p.startLiteral("false", false)
+	case c == 'n':
+		p.startLiteral("null", nil)
+	case c == '-' || (c >= '0' && c <= '9'):
+		p.state = stateNumber
+		p.pending = append(p.pending[:0], c)
+	default:
+		return p.errorf("invalid character %q", c)
+	}
+	return nil
+}
+
+func (p *Parser) startLiteral(literal string, value any) {
+	p.state = stateLiteral
+	p.literal, p.literalValue = literal, value
+	p.pending = append(p.pending[:0], literal[0])
+}
+
+// complete adds the terminated value to its object or array.
+func (p *Parser) complete(value any) {
+	p.state = stateAfterValue
+	if len(p.stack) == 0 {
+		p.root, p.done = value, true
+		return
+	}
+	top := p.stack[len(p.stack)-1]
+	if top.isArray {
+		top.array = append(top.array, value)
+	} else {
+		top.object[top.key] = value
+	}
+}
+
+func (p *Parser) closeContainer() {
+	top := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+	if top.isArray {
+		p.complete(slices.Clip(top.array))
+	} else {
+		p.complete(top.object)
+	}
+}
+
+func (p *Parser) consumeString(c byte) error {
+	if len(p.pending) > 0 {
+		p.pending = append(p.pending, c)
+		return p.decodePending()
+	}
+
+	switch {
+	case c == '"':
+		s := p.str.String()
+		p.str = strings.Builder{}
+		if p.key {
+			p.stack[len(p.stack)-1].key = s
+			p.state = stateColon
+		} else {
+			p.complete(s)
+		}
+	case c == '\\' || c >= utf8.RuneSelf:
+		p.pending = append(p.pending, c)
+		return p.decodePending()
+	case c < 0x20:
+		return p.errorf("invalid control character in string")
+	default:
+		p.str.WriteByte(c)
+	}
+	return nil
+}
+
+// decodePending adds the escape sequence or UTF-8 character of pending to the string once
+// it is complete, and parses the bytes following it again. Invalid UTF-8 is replaced with
+// U+FFFD as encoding/json does.
+func (p *Parser) decodePending() error {
+	var r rune
+	var n int
+	if p.pending[0] == '\\' {
+		var err error
+		r, n, err = decodeEscape(p.pending)
+		if errors.Is(err, errIncomplete) {
+			return nil
+		}
+		if err != nil {
+			return p.errorf("%s", err)
+		}
+	} else {
+		if !utf8.FullRune(p.pending) {
+			return nil
+		}
+		r, n = utf8.DecodeRune(p.pending)
+	}
+	p.str.WriteRune(r)
+
+	var buf [12]byte
+	rest := append(buf[:0], p.pending[n:]...)
+	p.pending = p.pending[:0]
+	for _, c := range rest {
+		if err := p.consume(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeEscape decodes the escape sequence data starts with and returns its length.
+func decodeEscape(data []byte) (rune, int, error) {
+	if len(data) < 2 {
+		return 0, 0, errIncomplete
+	}
+
+	switch data[1] {
+	case '"', '\\', '/':
+		return rune(data[1]), 2, nil
+	case 'b':
+		return '\b', 2, nil
+	case 'f':
+		return '\f', 2, nil
+	case 'n':
+		return '\n', 2, nil
+	case 'r':
+		return '\r', 2, nil
+	case 't':
+		return '\t', 2, nil
+	case 'u':
+		r, err := parseHex(data[2:])
+		if err != nil {
+			return 0, 0, err
+		}
+		if !utf16.IsSurrogate(r) {
+			return r, 6, nil
+		}
+		// a surrogate pair is two escape sequences
+		tail := data[6:]
+		if len(tail) < 6 {
+			if isEscapePrefix(tail) {
+				return 0, 0, errIncomplete
+			}
+			return utf8.RuneError, 6, nil
+		}
+		if tail[0] != '\\' || tail[1] != 'u' {
+			return utf8.RuneError, 6, nil
+		}
+		r2, err := parseHex(tail[2:])
+		if err != nil {
+			return 0, 0, err
+		}
+		if pair := utf16.DecodeRune(r, r2); pair != utf8.RuneError {
+			return pair, 12, nil
+		}
+		return utf8.RuneError, 6, nil
+	default:
+		return 0, 0, fmt.Errorf("invalid escape character %q", data[1])
+	}
+}
+
+// isEscapePrefix reports whether data is the beginning of a \uXXXX escape sequence.
+func isEscapePrefix(data []byte) bool {
+	for i, c := range data {
+		switch {
+		case i == 0 && c != '\\', i == 1 && c != 'u', i > 1 && !isHex(c):
+			return false
+		}
+	}
+	return true
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func parseHex(data []byte) (rune, error) {
+	for i := 0; i < len(data) && i < 4; i++ {
+		if !isHex(data[i]) {
+			return 0, fmt.Errorf("invalid unicode escape %q", data[:i+1])
+		}
+	}
+	if len(data) < 4 {
+		return 0, errIncomplete
+	}
+	n, err := strconv.ParseUint(string(data[:4]), 16, 16)
+	if err != nil {
+		return 0, err
+	}
+	return rune(n), nil
+}
@@ -0,0 +1,153 @@
+package partialjson_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/liushuangls/go-anthropic/v2/internal/partialjson"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want any
+	}{
+		{"empty", "", nil},
+		{"spaces", "  ", nil},
+		{"object start", "{", map[string]any{}},
+		{"partial key", `{"loc`, map[string]any{}},
+		{"key without value", `{"location":`, map[string]any{}},
+		{"partial string", `{"location":"San Fra`, map[string]any{"location": "San Fra"}},
+		{"partial escape", `{"content":"line\`, map[string]any{"content": "line"}},
+		{"escapes", `{"content":"a\n\"b\" é`, map[string]any{"content": "a\n\"b\" é"}},
+		{"partial unicode escape", `{"content":"caf\u00`, map[string]any{"content": "caf"}},
+		{"partial surrogate pair", `{"content":"\ud83d\ude`, map[string]any{"content": ""}},
+		{"surrogate pair", `{"content":"\ud83d\ude00`, map[string]any{"content": "😀"}},
+		{"partial utf8", "{\"content\":\"caf\xc3", map[string]any{"content": "caf"}},
+		{"partial number", `{"count":12`, map[string]any{}},
+		{"number", `{"count":12,`, map[string]any{"count": 12.0}},
+		{"partial literal", `{"ok":tr`, map[string]any{}},
+		{"literals", `{"a":true,"b":false,"c":null`, map[string]any{"a": true, "b": false, "c": nil}},
+		{"nested", `{"items":[{"name":"a"},{"na`, map[string]any{"items": []any{map[string]any{"name": "a"}, map[string]any{}}}},
+		{"array", `[1, "tw`, []any{1.0, "tw"}},
+		{"complete", `{"a":[1,2],"b":{"c":"d"}}`, map[string]any{"a": []any{1.0, 2.0}, "b": map[string]any{"c": "d"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := partialjson.Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.data, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{`}`, `{"a" 1}`, `{"a":1 "b"`, `[1 2]`, `{"a":tru}`, `{"a":"\x"}`, `{"a":1}}`, `{1:2}`} {
+		if _, err := partialjson.Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%q) expected error", data)
+		}
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	data := `{"name": "get_weather", "args": {"location": "San Francisco, CA", "days": [1, 2.5e1, -3],
+		"unit": null, "verbose": false, "note": "café 😀 \"quoted\" é"}}`
+
+	for i := 0; i < len(data); i++ {
+		if _, err := partialjson.Parse([]byte(data[:i])); err != nil {
+			t.Fatalf("Parse(%q) error = %v", data[:i], err)
+		}
+	}
+
+	var want any
+	if err := json.Unmarshal([]byte(data), &want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := partialjson.Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %#v, want %#v", got, want)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add([]byte(`{"a":[1,"b",{"c":null}],"d":"\u00e9\ud83d\ude00"}`))
+	f.Add([]byte(`[true, false, -1.5e3]`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var want any
+		if json.Unmarshal(data, &want) != nil {
+			_, _ = partialjson.Parse(data)
+			return
+		}
+		if _, ok := want.(float64); ok {
+			// a number alone can't be told apart from a partial one
+			return
+		}
+
+		for i := range data {
+			if _, err := partialjson.Parse(data[:i]); err != nil {
+				t.Fatalf("Parse(%q) error = %v", data[:i], err)
+			}
+		}
+		got, err := partialjson.Parse(data)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", data, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Parse(%q) = %#v, want %#v", data, got, want)
+		}
+	})
+}
+
+func TestParserFragments(t *testing.T) {
+	data := `{"name": "get_weather", "args": {"location": "San Francisco, CA", "days": [1, 2.5e1, -3],
+		"unit": null, "verbose": false, "note": "café 😀 \"quoted\" 😀 é"}}`
+
+	for size := 1; size <= 7; size++ {
+		var p partialjson.Parser
+		var values []any
+		var wants []any
+		for i := 0; i < len(data); i += size {
+			end := min(i+size, len(data))
+			if err := p.Write([]byte(data[i:end])); err != nil {
+				t.Fatalf("Write(%q) error = %v", data[i:end], err)
+			}
+			want, err := partialjson.Parse([]byte(data[:end]))
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", data[:end], err)
+			}
+			values = append(values, p.Value())
+			wants = append(wants, want)
+		}
+		// the values returned before are not modified by the following writes
+		if !reflect.DeepEqual(values, wants) {
+			t.Errorf("fragments of %d bytes: values = %#v, want %#v", size, values, wants)
+		}
+	}
+}
+
+func TestParserError(t *testing.T) {
+	var p partialjson.Parser
+	if err := p.Write([]byte(`{"a":1`)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := p.Write([]byte(` 2}`)); err == nil {
+		t.Fatal("Write() expected error")
+	}
+	if err := p.Write([]byte(`}`)); err == nil {
+		t.Error("Write() after an error expected error")
+	}
+	if v := p.Value(); v != nil {
+		t.Errorf("Value() = %#v, want nil", v)
+	}
+}
@@ -0,0 +1,101 @@
+package sigv4_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2/internal/sigv4"
+)
+
+// the credentials and time of the AWS Signature Version 4 test suite
+var (
+	testCredentials = sigv4.Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	testTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		wantSignature string
+	}{
+		{
+			name:          "get-vanilla",
+			method:        http.MethodGet,
+			url:           "https://example.amazonaws.com/",
+			wantSignature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "post-vanilla",
+			method:        http.MethodPost,
+			url:           "https://example.amazonaws.com/",
+			wantSignature: "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			name:          "get-vanilla-query-order-key-case",
+			method:        http.MethodGet,
+			url:           "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			wantSignature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sigv4.Sign(req, nil, testCredentials, "us-east-1", "service", testTime)
+
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %q", got)
+			}
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, Signature=" + tt.wantSignature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization = %q\nwant %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSignSessionToken(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://bedrock-runtime.us-east-1.amazonaws.com/model/a%3A0/invoke", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := testCredentials
+	creds.SessionToken = "session-token"
+	sigv4.Sign(req, []byte(`{}`), creds, "us-east-1", "bedrock", testTime)
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "session-token" {
+		t.Errorf("X-Amz-Security-Token = %q", got)
+	}
+	if got := req.Header.Get("Authorization"); !strings.Contains(got, "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("the session token is not signed: %q", got)
+	}
+}
+
+func TestSignIgnoresUnsignedHeaders(t *testing.T) {
+	sign := func(header http.Header) string {
+		req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		sigv4.Sign(req, nil, testCredentials, "us-east-1", "service", testTime)
+		return req.Header.Get("Authorization")
+	}
+
+	if sign(nil) != sign(http.Header{"Accept": {"text/event-stream"}}) {
+		t.Error("the signature depends on a header that is not signed")
+	}
+}
@@ -0,0 +1,176 @@
+// Package sse decodes server-sent event streams as specified by
+// https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"time"
+)
+
+const defaultEventType = "message"
+
+// bom is the UTF-8 byte order mark, stripped once from the start of the stream.
+var bom = []byte("\xEF\xBB\xBF")
+
+// Event is a dispatched server-sent event.
+type Event struct {
+	// ID is the last event ID set by the stream, it carries over to the following events.
+	ID string
+	// Event is the event type, "message" if the event has no event field.
+	Event string
+	// Data is the concatenation of the data fields of the event, joined by newlines.
+	Data []byte
+	// Retry is the last reconnection time set by the stream, zero if none.
+	Retry time.Duration
+}
+
+// Decoder reads events from a stream. Lines can end with CRLF, LF or CR and have no length limit.
+type Decoder struct {
+	r *bufio.Reader
+
+	line      []byte
+	lastWasCR bool
+	started   bool
+
+	lastEventID string
+	retry       time.Duration
+	eventType   string
+	data        []byte
+	hasData     bool
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Next returns the next event. It returns io.EOF at the end of the stream;
+// as the spec requires, an event that is not terminated by a blank line is discarded.
+func (d *Decoder) Next() (Event, error) {
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+		if !d.started {
+			d.started = true
+			line = bytes.TrimPrefix(line, bom)
+		}
+
+		if len(line) == 0 {
+			if event, ok := d.dispatch(); ok {
+				return event, nil
+			}
+			continue
+		}
+		d.processLine(line)
+	}
+}
+
+// readLine returns the next line without its line ending. A line that is not terminated before
+// the end of the stream is returned with a nil error, the next call then returns io.EOF.
+func (d *Decoder) readLine() ([]byte, error) {
+	d.line = d.line[:0]
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(d.line) > 0 {
+				return d.line, nil
+			}
+			return nil, err
+		}
+
+		if d.lastWasCR {
+			d.lastWasCR = false
+			if b == '\n' {
+				continue
+			}
+		}
+
+		switch b {
+		case '\r':
+			d.lastWasCR = true
+			return d.line, nil
+		case '\n':
+			return d.line, nil
+		default:
+			d.line = append(d.line, b)
+		}
+	}
+}
+
+func (d *Decoder) processLine(line []byte) {
+	if line[0] == ':' {
+		// comment
+		return
+	}
+
+	field, value := line, []byte(nil)
+	if i := bytes.IndexByte(line, ':'); i >= 0 {
+		field, value = line[:i], line[i+1:]
+		if len(value) > 0 && value[0] == ' ' {
+			value = value[1:]
+		}
+	}
+
+	switch string(field) {
+	case "event":
+		d.eventType = string(value)
+	case "data":
+		if d.hasData {
+			d.data = append(d.data, '\n')
+		}
+		d.data = append(d.data, value...)
+		d.hasData = true
+	case "id":
+		if bytes.IndexByte(value, 0) < 0 {
+			d.lastEventID = string(value)
+		}
+	case "retry":
+		if isDigits(value) {
+			if ms, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+				d.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+func (d *Decoder) dispatch() (Event, bool) {
+	defer func() {
+		d.eventType = ""
+		d.data = nil
+		d.hasData = false
+	}()
+
+	if !d.hasData {
+		return Event{}, false
+	}
+
+	eventType := d.eventType
+	if eventType == "" {
+		eventType = defaultEventType
+	}
+	if d.data == nil {
+		d.data = []byte{}
+	}
+	return Event{
+		ID:    d.lastEventID,
+		Event: eventType,
+		Data:  d.data,
+		Retry: d.retry,
+	}, true
+}
+
+func isDigits(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
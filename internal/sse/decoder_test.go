@@ -0,0 +1,156 @@
+package sse_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2/internal/sse"
+)
+
+func decodeAll(t testing.TB, r io.Reader) []sse.Event {
+	t.Helper()
+
+	var events []sse.Event
+	d := sse.NewDecoder(r)
+	for {
+		event, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("Next error: %v", err)
+		}
+		events = append(events, event)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []sse.Event
+	}{
+		{
+			name:  "single event",
+			input: "event: ping\ndata: {\"type\": \"ping\"}\n\n",
+			want:  []sse.Event{{Event: "ping", Data: []byte(`{"type": "ping"}`)}},
+		},
+		{
+			name:  "default event type and no space after colon",
+			input: "data:hello\n\n",
+			want:  []sse.Event{{Event: "message", Data: []byte("hello")}},
+		},
+		{
+			name:  "multi-line data",
+			input: "event: completion\ndata: first\ndata:  second\ndata\n\n",
+			want:  []sse.Event{{Event: "completion", Data: []byte("first\n second\n")}},
+		},
+		{
+			name:  "comments and unknown fields are ignored",
+			input: ": keep-alive\nfoo: bar\nevent: ping\ndata: x\n\n:\n\n",
+			want:  []sse.Event{{Event: "ping", Data: []byte("x")}},
+		},
+		{
+			name:  "CRLF and CR line endings",
+			input: "event: a\r\ndata: 1\r\n\r\nevent: b\rdata: 2\r\r",
+			want: []sse.Event{
+				{Event: "a", Data: []byte("1")},
+				{Event: "b", Data: []byte("2")},
+			},
+		},
+		{
+			name:  "id and retry carry over",
+			input: "id: 1\nretry: 1500\ndata: a\n\ndata: b\n\nid\nretry: x\ndata: c\n\n",
+			want: []sse.Event{
+				{ID: "1", Event: "message", Data: []byte("a"), Retry: 1500 * time.Millisecond},
+				{ID: "1", Event: "message", Data: []byte("b"), Retry: 1500 * time.Millisecond},
+				{ID: "", Event: "message", Data: []byte("c"), Retry: 1500 * time.Millisecond},
+			},
+		},
+		{
+			name:  "event without data is not dispatched",
+			input: "event: ping\n\nevent: pong\ndata: y\n\n",
+			want:  []sse.Event{{Event: "pong", Data: []byte("y")}},
+		},
+		{
+			name:  "empty data is dispatched",
+			input: "data:\n\n",
+			want:  []sse.Event{{Event: "message", Data: []byte{}}},
+		},
+		{
+			name:  "leading byte order mark is stripped once",
+			input: "\uFEFFevent: ping\ndata: a\n\n\uFEFFevent: pong\ndata: b\n\n",
+			want: []sse.Event{
+				{Event: "ping", Data: []byte("a")},
+				{Event: "message", Data: []byte("b")},
+			},
+		},
+		{
+			name:  "unterminated event is discarded",
+			input: "data: a\n\ndata: b\n",
+			want:  []sse.Event{{Event: "message", Data: []byte("a")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeAll(t, strings.NewReader(tt.input))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+
+			got = decodeAll(t, iotest.OneByteReader(strings.NewReader(tt.input)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("one byte reader: got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoderLongLine(t *testing.T) {
+	data := strings.Repeat("a", 1<<20)
+	got := decodeAll(t, strings.NewReader("data: "+data+"\n\n"))
+	if len(got) != 1 || string(got[0].Data) != data {
+		t.Fatalf("long line not decoded")
+	}
+}
+
+func TestDecoderReadError(t *testing.T) {
+	readErr := errors.New("connection reset")
+	d := sse.NewDecoder(io.MultiReader(strings.NewReader("data: a\n"), iotest.ErrReader(readErr)))
+	if _, err := d.Next(); !errors.Is(err, readErr) {
+		t.Fatalf("expected read error, got %v", err)
+	}
+}
+
+func FuzzDecoder(f *testing.F) {
+	f.Add([]byte("event: ping\ndata: {\"type\": \"ping\"}\n\n"))
+	f.Add([]byte("data: a\r\ndata: b\r\n\r\n"))
+	f.Add([]byte("id: 1\rretry: 10\rdata\r\r: comment\n\n"))
+	f.Add([]byte("data: a\n\ndata: b"))
+	f.Add([]byte("\r\n\r\r\n\n:"))
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		events := decodeAll(t, bytes.NewReader(input))
+		for _, event := range events {
+			if event.Event == "" {
+				t.Fatalf("dispatched event without type: %+v", event)
+			}
+			if bytes.ContainsAny([]byte(event.Event), "\r\n") || strings.ContainsAny(event.ID, "\r\n\x00") {
+				t.Fatalf("line ending leaked into field: %+v", event)
+			}
+		}
+
+		// decoding must not depend on how the input is chunked
+		oneByte := decodeAll(t, iotest.OneByteReader(bytes.NewReader(input)))
+		if !reflect.DeepEqual(events, oneByte) {
+			t.Fatalf("chunking changed the result: %+v != %+v", events, oneByte)
+		}
+	})
+}
@@ -4,6 +4,9 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"testing"
+
+	"github.com/liushuangls/go-anthropic/v2"
 )
 
 const testAPI = "this-is-my-secure-token-do-not-steal!!"
@@ -45,3 +48,16 @@ func (ts *ServerTest) AnthropicTestServer() *httptest.Server {
 		handlerCall(w, r)
 	}))
 }
+
+// NewClient starts the server and returns a client of it with the test token and the options.
+// The server is closed when the test ends.
+func (ts *ServerTest) NewClient(t testing.TB, opts ...anthropic.ClientOption) *anthropic.Client {
+	t.Helper()
+
+	server := ts.AnthropicTestServer()
+	server.Start()
+	t.Cleanup(server.Close)
+
+	opts = append([]anthropic.ClientOption{anthropic.WithBaseURL(server.URL + "/v1")}, opts...)
+	return anthropic.NewClient(GetTestToken(), opts...)
+}
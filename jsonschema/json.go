@@ -1,10 +1,14 @@
 // Package jsonschema provides very simple functionality for representing a JSON schema as a
-// (nested) struct. This struct can be used with the messages "tool use" feature.
+// (nested) struct. This struct can be used with the messages "tool use" feature, and can
+// either be written by hand or generated from a Go type with Reflect.
 // For more complicated schemas, it is recommended to use a dedicated JSON schema library
 // and/or pass in the schema in []byte format.
 package jsonschema
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 type DataType string
 
@@ -19,21 +23,62 @@ const (
 )
 
 // Definition is a struct for describing a JSON Schema.
-// It is fairly limited, and you may have better luck using a third-party library.
+// It covers the keywords commonly used by tool input schemas, for anything else
+// you may have better luck using a third-party library.
 type Definition struct {
 	// Type specifies the data type of the schema.
 	Type DataType `json:"type,omitempty"`
 	// Description is the description of the schema.
 	Description string `json:"description,omitempty"`
 	// Enum is used to restrict a value to a fixed set of values. It must be an array with at least
-	// one element, where each element is unique. You will probably only use this with strings.
+	// one element, where each element is unique. You will probably only use this with strings,
+	// see EnumValues for other values.
 	Enum []string `json:"enum,omitempty"`
+	// EnumValues restricts a value to a fixed set of values of any JSON type, e.g. numbers.
+	// It is marshaled as the enum instead of Enum when set, and holds the enums that are not
+	// all strings when unmarshaling.
+	EnumValues []any `json:"-"`
 	// Properties describes the properties of an object, if the schema type is Object.
 	Properties map[string]Definition `json:"properties"`
 	// Required specifies which properties are required, if the schema type is Object.
 	Required []string `json:"required,omitempty"`
 	// Items specifies which data type an array contains, if the schema type is Array.
 	Items *Definition `json:"items,omitempty"`
+	// Nullable allows the value to also be null, the type is then marshaled as [Type, "null"].
+	Nullable bool `json:"-"`
+	// Ref references another schema, e.g. "#/$defs/Node" for a definition in Defs or "#" for the root.
+	Ref string `json:"$ref,omitempty"`
+	// Defs holds the definitions referenced with Ref, it is only set on the root schema.
+	Defs map[string]Definition `json:"$defs,omitempty"`
+
+	// AnyOf requires the value to match at least one of the schemas.
+	AnyOf []Definition `json:"anyOf,omitempty"`
+	// OneOf requires the value to match exactly one of the schemas.
+	OneOf []Definition `json:"oneOf,omitempty"`
+	// AllOf requires the value to match all the schemas.
+	AllOf []Definition `json:"allOf,omitempty"`
+	// AdditionalProperties describes the properties of an object not listed in Properties,
+	// either a bool allowing or forbidding them, or a Definition they must match.
+	AdditionalProperties any `json:"additionalProperties,omitempty"`
+
+	// Format is the semantic format of a string, e.g. "date-time", "email" or "uuid".
+	Format string `json:"format,omitempty"`
+	// Pattern is a regular expression a string must match.
+	Pattern string `json:"pattern,omitempty"`
+	// MinLength and MaxLength bound the number of characters of a string.
+	MinLength *int `json:"minLength,omitempty"`
+	MaxLength *int `json:"maxLength,omitempty"`
+	// Minimum and Maximum bound a number, inclusively.
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+	// MinItems and MaxItems bound the number of items of an array.
+	MinItems *int `json:"minItems,omitempty"`
+	MaxItems *int `json:"maxItems,omitempty"`
+
+	// Default is the value used when none is given.
+	Default any `json:"default,omitempty"`
+	// Const restricts the value to a single value.
+	Const any `json:"const,omitempty"`
 }
 
 func (d Definition) MarshalJSON() ([]byte, error) {
@@ -41,9 +86,110 @@ func (d Definition) MarshalJSON() ([]byte, error) {
 		d.Properties = make(map[string]Definition)
 	}
 	type Alias Definition
+	var typ any
+	if d.Type != "" {
+		typ = d.Type
+		if d.Nullable && d.Type != Null {
+			typ = []DataType{d.Type, Null}
+		}
+	}
+	var enum any
+	if len(d.EnumValues) > 0 {
+		enum = d.EnumValues
+	} else if len(d.Enum) > 0 {
+		enum = d.Enum
+	}
 	return json.Marshal(struct {
 		Alias
+		Type any `json:"type,omitempty"`
+		Enum any `json:"enum,omitempty"`
 	}{
 		Alias: (Alias)(d),
+		Type:  typ,
+		Enum:  enum,
 	})
 }
+
+// UnmarshalJSON loads a schema, e.g. from a file. A type array with "null" sets Nullable,
+// and a type array of several other types is loaded as AnyOf. An enum of strings is loaded
+// as Enum, any other enum as EnumValues.
+func (d *Definition) UnmarshalJSON(data []byte) error {
+	type Alias Definition
+	aux := struct {
+		*Alias
+		Type                 json.RawMessage `json:"type,omitempty"`
+		Enum                 []any           `json:"enum,omitempty"`
+		AdditionalProperties json.RawMessage `json:"additionalProperties,omitempty"`
+	}{
+		Alias: (*Alias)(d),
+	}
+	*d = Definition{}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	if err := d.unmarshalType(aux.Type); err != nil {
+		return err
+	}
+
+	for _, v := range aux.Enum {
+		s, ok := v.(string)
+		if !ok {
+			d.Enum, d.EnumValues = nil, aux.Enum
+			break
+		}
+		d.Enum = append(d.Enum, s)
+	}
+
+	if len(aux.AdditionalProperties) > 0 {
+		var allowed bool
+		if err := json.Unmarshal(aux.AdditionalProperties, &allowed); err == nil {
+			d.AdditionalProperties = allowed
+		} else {
+			var def Definition
+			if err := json.Unmarshal(aux.AdditionalProperties, &def); err != nil {
+				return fmt.Errorf("jsonschema: invalid additionalProperties: %w", err)
+			}
+			d.AdditionalProperties = def
+		}
+	}
+	return nil
+}
+
+func (d *Definition) unmarshalType(data json.RawMessage) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, &d.Type); err == nil {
+		return nil
+	}
+
+	var types []DataType
+	if err := json.Unmarshal(data, &types); err != nil {
+		return fmt.Errorf("jsonschema: invalid type: %w", err)
+	}
+	var others []DataType
+	for _, t := range types {
+		if t == Null {
+			d.Nullable = true
+		} else {
+			others = append(others, t)
+		}
+	}
+
+	switch len(others) {
+	case 0:
+		d.Type, d.Nullable = Null, false
+	case 1:
+		d.Type = others[0]
+	default:
+		for _, t := range others {
+			d.AnyOf = append(d.AnyOf, Definition{Type: t})
+		}
+		if d.Nullable {
+			d.AnyOf = append(d.AnyOf, Definition{Type: Null})
+			d.Nullable = false
+		}
+	}
+	return nil
+}
@@ -209,3 +209,102 @@ func structToMap(t *testing.T, v any) map[string]any {
 	}
 	return got
 }
+
+func TestDefinition_UnmarshalJSON(t *testing.T) {
+	data := `{
+   "type":"object",
+   "properties":{
+      "name":{"type":["string","null"],"minLength":1,"pattern":"^[A-Z]","properties":{}},
+      "id":{"type":["string","integer"],"properties":{}},
+      "size":{"type":"integer","enum":[1,2],"minimum":0,"maximum":100,"default":1,"properties":{}},
+      "kind":{"const":"user","properties":{}},
+      "created":{"type":"string","format":"date-time","properties":{}},
+      "tags":{"type":"array","items":{"type":"string","properties":{}},"minItems":1,"maxItems":5,"properties":{}},
+      "labels":{"type":"object","additionalProperties":{"type":"string","properties":{}},"properties":{}},
+      "parent":{"anyOf":[{"$ref":"#/$defs/node","properties":{}},{"type":"null","properties":{}}],"properties":{}}
+   },
+   "required":["name"],
+   "additionalProperties":false,
+   "$defs":{
+      "node":{"type":"object","allOf":[{"required":["id"],"properties":{}}],"oneOf":[{"type":"object","properties":{}}],"properties":{}}
+   }
+}`
+
+	var def jsonschema.Definition
+	if err := json.Unmarshal([]byte(data), &def); err != nil {
+		t.Fatalf("UnmarshalJSON() error = %v", err)
+	}
+
+	name := def.Properties["name"]
+	if name.Type != jsonschema.String || !name.Nullable || name.MinLength == nil || *name.MinLength != 1 {
+		t.Errorf("unexpected name definition: %+v", name)
+	}
+	if id := def.Properties["id"]; id.Type != "" || len(id.AnyOf) != 2 || id.AnyOf[1].Type != jsonschema.Integer {
+		t.Errorf("unexpected id definition: %+v", id)
+	}
+	if size := def.Properties["size"]; len(size.Enum) != 0 || len(size.EnumValues) != 2 || size.Maximum == nil || *size.Maximum != 100 {
+		t.Errorf("unexpected size definition: %+v", size)
+	}
+	if additional, ok := def.AdditionalProperties.(bool); !ok || additional {
+		t.Errorf("unexpected additionalProperties: %#v", def.AdditionalProperties)
+	}
+	if labels, ok := def.Properties["labels"].AdditionalProperties.(jsonschema.Definition); !ok || labels.Type != jsonschema.String {
+		t.Errorf("unexpected labels additionalProperties: %#v", def.Properties["labels"].AdditionalProperties)
+	}
+	if _, ok := def.Defs["node"]; !ok {
+		t.Errorf("missing $defs: %+v", def.Defs)
+	}
+
+	var want map[string]any
+	if err := json.Unmarshal([]byte(data), &want); err != nil {
+		t.Fatalf("Failed to Unmarshal JSON: error = %v", err)
+	}
+	// a type array of several types is loaded as anyOf
+	want["properties"].(map[string]any)["id"] = map[string]any{
+		"anyOf": []any{
+			map[string]any{"type": "string", "properties": map[string]any{}},
+			map[string]any{"type": "integer", "properties": map[string]any{}},
+		},
+		"properties": map[string]any{},
+	}
+	if got := structToMap(t, def); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip got = %v, want %v", got, want)
+	}
+}
+
+func TestDefinition_EnumValuesRoundTrip(t *testing.T) {
+	data := `{"type":"object","properties":{` +
+		`"size":{"type":"integer","enum":[1,2],"properties":{}},` +
+		`"mixed":{"enum":["a",1.5,true,null],"properties":{}},` +
+		`"unit":{"type":"string","enum":["kg","g"],"properties":{}}}}`
+
+	var def jsonschema.Definition
+	if err := json.Unmarshal([]byte(data), &def); err != nil {
+		t.Fatalf("UnmarshalJSON() error = %v", err)
+	}
+	if unit := def.Properties["unit"]; len(unit.Enum) != 2 || unit.EnumValues != nil {
+		t.Errorf("unexpected unit definition: %+v", unit)
+	}
+
+	got, err := json.Marshal(def)
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	var gotMap, wantMap map[string]any
+	if err := json.Unmarshal(got, &gotMap); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(data), &wantMap); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotMap, wantMap) {
+		t.Errorf("round trip got = %s, want %s", got, data)
+	}
+
+	if err := def.Validate(json.RawMessage(`{"size":2,"mixed":null,"unit":"g"}`)); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := def.Validate(json.RawMessage(`{"size":3}`)); err == nil {
+		t.Error("Validate() should fail for a value not in the enum")
+	}
+}
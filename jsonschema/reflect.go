@@ -0,0 +1,395 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Reflect generates the schema of the type of v, see GenerateSchemaForType.
+func Reflect(v any) (*Definition, error) {
+	if v == nil {
+		return nil, errors.New("jsonschema: cannot reflect nil")
+	}
+	return GenerateSchemaForType(reflect.TypeOf(v))
+}
+
+// GenerateSchemaForType generates the schema of a Go type the way encoding/json marshals it.
+//
+// Struct fields are named after their json tag, and fields tagged with `json:"-"` are skipped.
+// The jsonschema tag sets a field's schema options, separated by commas:
+//
+//	Name string `json:"name" jsonschema:"description=The name of the user,required"`
+//	Unit string `json:"unit" jsonschema:"enum=celsius,enum=fahrenheit"`
+//
+// The options are description, enum (repeated for each value), required, format, pattern,
+// minimum, maximum, minLength, maxLength, minItems, maxItems and default, whose value is
+// parsed as JSON, falling back to a string. The enum values of fields of other types than
+// string are parsed as JSON too, e.g. `jsonschema:"enum=1,enum=2"` for an int.
+//
+// Pointer fields are nullable, null then being part of their enum, embedded structs are flattened
+// into their parent as encoding/json does, time.Time is a date-time string and recursive types
+// are referenced from the $defs of the root schema.
+func GenerateSchemaForType(t reflect.Type) (*Definition, error) {
+	if t == nil {
+		return nil, errors.New("jsonschema: cannot reflect nil")
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	r := &reflector{
+		root:      t,
+		visiting:  map[reflect.Type]bool{},
+		recursive: map[reflect.Type]bool{},
+		names:     map[reflect.Type]string{},
+		defs:      map[string]Definition{},
+	}
+	def, err := r.reflect(t)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.defs) > 0 {
+		def.Defs = r.defs
+	}
+	return &def, nil
+}
+
+type reflector struct {
+	root reflect.Type
+	// visiting holds the struct types being reflected, to detect recursion.
+	visiting map[reflect.Type]bool
+	// recursive holds the struct types referencing themselves.
+	recursive map[reflect.Type]bool
+	names     map[reflect.Type]string
+	defs      map[string]Definition
+}
+
+func (r *reflector) reflect(t reflect.Type) (Definition, error) {
+	switch t {
+	case timeType:
+		return Definition{Type: String, Format: "date-time"}, nil
+	case rawMessageType:
+		return Definition{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Definition{Type: Boolean}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Definition{Type: Integer}, nil
+	case reflect.Float32, reflect.Float64:
+		return Definition{Type: Number}, nil
+	case reflect.String:
+		return Definition{Type: String}, nil
+	case reflect.Interface:
+		return Definition{}, nil
+	case reflect.Pointer:
+		def, err := r.reflect(t.Elem())
+		if err != nil {
+			return Definition{}, err
+		}
+		if def.Ref != "" {
+			return Definition{AnyOf: []Definition{def, {Type: Null}}}, nil
+		}
+		def.Nullable = true
+		return def, nil
+	case reflect.Slice, reflect.Array:
+		// encoding/json marshals byte slices as base64 strings
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return Definition{Type: String}, nil
+		}
+		items, err := r.reflect(t.Elem())
+		if err != nil {
+			return Definition{}, err
+		}
+		return Definition{Type: Array, Items: &items}, nil
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			return Definition{}, fmt.Errorf("jsonschema: unsupported map key type %s", t.Key())
+		}
+		def := Definition{Type: Object}
+		if t.Elem().Kind() != reflect.Interface {
+			values, err := r.reflect(t.Elem())
+			if err != nil {
+				return Definition{}, err
+			}
+			def.AdditionalProperties = values
+		}
+		return def, nil
+	case reflect.Struct:
+		return r.reflectStruct(t)
+	default:
+		return Definition{}, fmt.Errorf("jsonschema: unsupported type %s", t)
+	}
+}
+
+func (r *reflector) reflectStruct(t reflect.Type) (Definition, error) {
+	if r.visiting[t] {
+		r.recursive[t] = true
+		return Definition{Ref: r.ref(t)}, nil
+	}
+	if r.recursive[t] && t != r.root {
+		return Definition{Ref: r.ref(t)}, nil
+	}
+
+	r.visiting[t] = true
+	def := Definition{Type: Object, Properties: map[string]Definition{}}
+	err := r.reflectFields(t, &def)
+	delete(r.visiting, t)
+	if err != nil {
+		return Definition{}, err
+	}
+
+	if r.recursive[t] && t != r.root {
+		ref := r.ref(t)
+		r.defs[strings.TrimPrefix(ref, "#/$defs/")] = def
+		return Definition{Ref: ref}, nil
+	}
+	return def, nil
+}
+
+// reflectFields adds the fields of the struct type t to def, flattening embedded structs.
+func (r *reflector) reflectFields(t reflect.Type, def *Definition) error {
+	for _, f := range structFields(t) {
+		prop, err := r.reflect(f.field.Type)
+		if err != nil {
+			return fmt.Errorf("%w (field %s.%s)", err, f.owner.Name(), f.field.Name)
+		}
+		required, err := parseTag(f.field.Tag.Get("jsonschema"), &prop)
+		if err != nil {
+			return fmt.Errorf("%w (field %s.%s)", err, f.owner.Name(), f.field.Name)
+		}
+
+		def.Properties[f.name] = prop
+		if required {
+			def.Required = append(def.Required, f.name)
+		}
+	}
+	return nil
+}
+
+// structField is a field of a struct or of the structs embedded in it.
+type structField struct {
+	name   string
+	field  reflect.StructField
+	owner  reflect.Type
+	index  []int
+	tagged bool
+}
+
+// dominates reports whether f hides the other field o of the same name, or makes it ambiguous.
+func (f structField) dominates(o structField) bool {
+	if f.name != o.name || slices.Equal(f.index, o.index) {
+		return false
+	}
+	return len(f.index) < len(o.index) || len(f.index) == len(o.index) && (f.tagged || !o.tagged)
+}
+
+// structFields returns the fields of the struct type t that encoding/json marshals, in order.
+// Of the fields with the same name, the least embedded one wins, or the tagged one if several
+// are embedded as deep; the fields left ambiguous are dropped.
+func structFields(t reflect.Type) []structField {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+	// depths holds the depth each struct type was first visited at
+	depths := map[reflect.Type]int{}
+	var fields []structField
+	next := []embedded{{typ: t}}
+	for depth := 0; len(next) > 0; depth++ {
+		current := next
+		next = nil
+		for _, e := range current {
+			if d, ok := depths[e.typ]; ok && d < depth {
+				continue
+			}
+			depths[e.typ] = depth
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				field := e.typ.Field(i)
+				name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+				if name == "-" && opts == "" {
+					continue
+				}
+				index := append(slices.Clip(e.index), i)
+
+				if field.Anonymous && name == "" {
+					ft := field.Type
+					if ft.Kind() == reflect.Pointer {
+						// encoding/json can not set the fields of an unexported embedded pointer
+						if !field.IsExported() {
+							continue
+						}
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						next = append(next, embedded{typ: ft, index: index})
+						continue
+					}
+				}
+				if !field.IsExported() {
+					continue
+				}
+				f := structField{name: name, field: field, owner: e.typ, index: index, tagged: name != ""}
+				if name == "" {
+					f.name = field.Name
+				}
+				fields = append(fields, f)
+			}
+		}
+	}
+
+	var dominant []structField
+	for _, f := range fields {
+		if !slices.ContainsFunc(fields, func(g structField) bool { return g.dominates(f) }) {
+			dominant = append(dominant, f)
+		}
+	}
+	slices.SortFunc(dominant, func(a, b structField) int {
+		return slices.Compare(a.index, b.index)
+	})
+	return dominant
+}
+
+// ref returns the reference to the struct type t, naming its definition after the type.
+func (r *reflector) ref(t reflect.Type) string {
+	if t == r.root {
+		return "#"
+	}
+	if name, ok := r.names[t]; ok {
+		return "#/$defs/" + name
+	}
+
+	base := t.Name()
+	if base == "" {
+		base = "Type"
+	}
+	taken := map[string]bool{}
+	for _, n := range r.names {
+		taken[n] = true
+	}
+	name := base
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	r.names[t] = name
+	return "#/$defs/" + name
+}
+
+// parseTag applies the options of a jsonschema tag to def and reports whether the field is required.
+// A comma not followed by a known option is part of the previous value, so descriptions may contain commas.
+func parseTag(tag string, def *Definition) (required bool, err error) {
+	if tag == "" {
+		return false, nil
+	}
+
+	var options []string
+	for _, part := range strings.Split(tag, ",") {
+		key, _, _ := strings.Cut(part, "=")
+		switch key {
+		case "description", "enum", "required", "format", "pattern", "minimum", "maximum",
+			"minLength", "maxLength", "minItems", "maxItems", "default":
+			options = append(options, part)
+		default:
+			if len(options) == 0 {
+				return false, fmt.Errorf("jsonschema: unknown tag option %q", part)
+			}
+			options[len(options)-1] += "," + part
+		}
+	}
+
+	var enum []string
+	for _, option := range options {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "description":
+			def.Description = value
+		case "enum":
+			enum = append(enum, value)
+		case "required":
+			required = true
+		case "format":
+			def.Format = value
+		case "pattern":
+			def.Pattern = value
+		case "minimum", "maximum":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, fmt.Errorf("jsonschema: invalid %s %q", key, value)
+			}
+			if key == "minimum" {
+				def.Minimum = &f
+			} else {
+				def.Maximum = &f
+			}
+		case "minLength", "maxLength", "minItems", "maxItems":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return false, fmt.Errorf("jsonschema: invalid %s %q", key, value)
+			}
+			switch key {
+			case "minLength":
+				def.MinLength = &n
+			case "maxLength":
+				def.MaxLength = &n
+			case "minItems":
+				def.MinItems = &n
+			case "maxItems":
+				def.MaxItems = &n
+			}
+		case "default":
+			if err := json.Unmarshal([]byte(value), &def.Default); err != nil {
+				def.Default = value
+			}
+		}
+	}
+	if len(enum) > 0 {
+		if err := setEnum(def, enum); err != nil {
+			return false, err
+		}
+	}
+	return required, nil
+}
+
+// setEnum sets the enum of def from the values of the tag. They are strings for string types,
+// and are parsed as JSON for the others, falling back to a string for values of any type.
+// The enum of a nullable type also allows null.
+func setEnum(def *Definition, values []string) error {
+	if def.Type == String && !def.Nullable {
+		def.Enum = values
+		return nil
+	}
+
+	for _, value := range values {
+		var v any
+		if def.Type == String {
+			v = value
+		} else if err := json.Unmarshal([]byte(value), &v); err != nil {
+			if def.Type != "" {
+				return fmt.Errorf("jsonschema: invalid enum %q", value)
+			}
+			v = value
+		}
+		def.EnumValues = append(def.EnumValues, v)
+	}
+	if def.Nullable {
+		def.EnumValues = append(def.EnumValues, nil)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package jsonschema_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2/jsonschema"
+)
+
+type testAddress struct {
+	City    string `json:"city" jsonschema:"description=The city, e.g. San Francisco,required"`
+	Country string `json:"country,omitempty"`
+}
+
+type testBase struct {
+	ID        int       `json:"id" jsonschema:"required"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type testUser struct {
+	testBase
+	Name     string            `json:"name" jsonschema:"description=The name of the user,required"`
+	Role     string            `json:"role" jsonschema:"enum=admin,enum=member"`
+	Score    float64           `json:"score"`
+	Active   bool              `json:"active"`
+	Tags     []string          `json:"tags"`
+	Address  *testAddress      `json:"address"`
+	Labels   map[string]string `json:"labels"`
+	Avatar   []byte            `json:"avatar"`
+	Extra    any               `json:"extra"`
+	Internal string            `json:"-"`
+	private  string
+	NoTag    int
+	Count    int    `json:"count" jsonschema:"minimum=1,maximum=10,default=5"`
+	Code     string `json:"code" jsonschema:"pattern=^[a-z]{2,3}$,minLength=2,format=hostname"`
+}
+
+type testNode struct {
+	Value    string      `json:"value"`
+	Children []*testNode `json:"children"`
+}
+
+type testTree struct {
+	Name string    `json:"name"`
+	Root *testNode `json:"root"`
+	Alt  *testNode `json:"alt"`
+}
+
+func TestReflect(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{
+			name: "Test with scalar",
+			v:    "",
+			want: `{"type":"string","properties":{}}`,
+		},
+		{
+			name: "Test with struct",
+			v:    &testUser{},
+			want: `{
+   "type":"object",
+   "properties":{
+      "id":{"type":"integer","properties":{}},
+      "created_at":{"type":"string","format":"date-time","properties":{}},
+      "name":{"type":"string","description":"The name of the user","properties":{}},
+      "role":{"type":"string","enum":["admin","member"],"properties":{}},
+      "score":{"type":"number","properties":{}},
+      "active":{"type":"boolean","properties":{}},
+      "tags":{"type":"array","items":{"type":"string","properties":{}},"properties":{}},
+      "address":{
+         "type":["object","null"],
+         "properties":{
+            "city":{"type":"string","description":"The city, e.g. San Francisco","properties":{}},
+            "country":{"type":"string","properties":{}}
+         },
+         "required":["city"]
+      },
+      "labels":{"type":"object","additionalProperties":{"type":"string","properties":{}},"properties":{}},
+      "avatar":{"type":"string","properties":{}},
+      "extra":{"properties":{}},
+      "NoTag":{"type":"integer","properties":{}},
+      "count":{"type":"integer","minimum":1,"maximum":10,"default":5,"properties":{}},
+      "code":{"type":"string","pattern":"^[a-z]{2,3}$","minLength":2,"format":"hostname","properties":{}}
+   },
+   "required":["id","name"]
+}`,
+		},
+		{
+			name: "Test with self recursive struct",
+			v:    testNode{},
+			want: `{
+   "type":"object",
+   "properties":{
+      "value":{"type":"string","properties":{}},
+      "children":{"type":"array","items":{"anyOf":[{"$ref":"#","properties":{}},{"type":"null","properties":{}}],"properties":{}},"properties":{}}
+   }
+}`,
+		},
+		{
+			name: "Test with nested recursive struct",
+			v:    testTree{},
+			want: `{
+   "type":"object",
+   "properties":{
+      "name":{"type":"string","properties":{}},
+      "root":{"anyOf":[{"$ref":"#/$defs/testNode","properties":{}},{"type":"null","properties":{}}],"properties":{}},
+      "alt":{"anyOf":[{"$ref":"#/$defs/testNode","properties":{}},{"type":"null","properties":{}}],"properties":{}}
+   },
+   "$defs":{
+      "testNode":{
+         "type":"object",
+         "properties":{
+            "value":{"type":"string","properties":{}},
+            "children":{"type":"array","items":{"anyOf":[{"$ref":"#/$defs/testNode","properties":{}},{"type":"null","properties":{}}],"properties":{}},"properties":{}}
+         }
+      }
+   }
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def, err := jsonschema.Reflect(tt.v)
+			if err != nil {
+				t.Fatalf("Reflect() error = %v", err)
+			}
+
+			var want map[string]any
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatalf("Failed to Unmarshal JSON: error = %v", err)
+			}
+			got := structToMap(t, def)
+			if !reflect.DeepEqual(got, want) {
+				gotBytes, _ := json.Marshal(def)
+				t.Errorf("Reflect() got = %s, want %s", gotBytes, tt.want)
+			}
+		})
+	}
+}
+
+type testNamed struct {
+	Name  int `json:"name" jsonschema:"required"`
+	Title string
+	Note  string
+}
+
+type testLabeled struct {
+	Title int
+	Note  string `json:"Note"`
+}
+
+type testEmbedding struct {
+	testNamed
+	testLabeled
+	Name string `json:"name" jsonschema:"required"`
+}
+
+// RecursiveEmbedding is exported, encoding/json ignores unexported embedded pointers.
+type RecursiveEmbedding struct {
+	*RecursiveEmbedding
+	Value string `json:"value"`
+}
+
+func TestReflectEmbeddedFields(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{
+			// the outer name hides the embedded one, the ambiguous titles are dropped
+			// and the tagged Note wins over the untagged one
+			name: "Test with conflicting fields",
+			v:    testEmbedding{},
+			want: `{
+   "type":"object",
+   "properties":{
+      "name":{"type":"string","properties":{}},
+      "Note":{"type":"string","properties":{}}
+   },
+   "required":["name"]
+}`,
+		},
+		{
+			name: "Test with recursive embedding",
+			v:    RecursiveEmbedding{},
+			want: `{"type":"object","properties":{"value":{"type":"string","properties":{}}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def, err := jsonschema.Reflect(tt.v)
+			if err != nil {
+				t.Fatalf("Reflect() error = %v", err)
+			}
+
+			var want map[string]any
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatalf("Failed to Unmarshal JSON: error = %v", err)
+			}
+			if got := structToMap(t, def); !reflect.DeepEqual(got, want) {
+				gotBytes, _ := json.Marshal(def)
+				t.Errorf("Reflect() got = %s, want %s", gotBytes, tt.want)
+			}
+		})
+	}
+}
+
+type testEnums struct {
+	Level   int     `json:"level" jsonschema:"enum=1,enum=2,enum=3"`
+	Ratio   float64 `json:"ratio" jsonschema:"enum=0.5,enum=1"`
+	Enabled bool    `json:"enabled" jsonschema:"enum=true"`
+	Unit    *string `json:"unit" jsonschema:"enum=celsius,enum=fahrenheit"`
+	Mode    *int    `json:"mode" jsonschema:"enum=0,enum=1"`
+	Any     any     `json:"any" jsonschema:"enum=1,enum=auto"`
+}
+
+func TestReflectEnums(t *testing.T) {
+	def, err := jsonschema.Reflect(testEnums{})
+	if err != nil {
+		t.Fatalf("Reflect() error = %v", err)
+	}
+
+	want := `{
+   "type":"object",
+   "properties":{
+      "level":{"type":"integer","enum":[1,2,3],"properties":{}},
+      "ratio":{"type":"number","enum":[0.5,1],"properties":{}},
+      "enabled":{"type":"boolean","enum":[true],"properties":{}},
+      "unit":{"type":["string","null"],"enum":["celsius","fahrenheit",null],"properties":{}},
+      "mode":{"type":["integer","null"],"enum":[0,1,null],"properties":{}},
+      "any":{"enum":[1,"auto"],"properties":{}}
+   }
+}`
+	var wantMap map[string]any
+	if err := json.Unmarshal([]byte(want), &wantMap); err != nil {
+		t.Fatalf("Failed to Unmarshal JSON: error = %v", err)
+	}
+	if got := structToMap(t, def); !reflect.DeepEqual(got, wantMap) {
+		gotBytes, _ := json.Marshal(def)
+		t.Errorf("Reflect() got = %s, want %s", gotBytes, want)
+	}
+
+	// the enums are validated by their JSON type
+	for _, tt := range []struct {
+		input string
+		valid bool
+	}{
+		{`{"level":2,"mode":null,"unit":null}`, true},
+		{`{"level":"2"}`, false},
+		{`{"enabled":false}`, false},
+		{`{"mode":2}`, false},
+		{`{"unit":"kelvin"}`, false},
+	} {
+		if err := def.Validate([]byte(tt.input)); (err == nil) != tt.valid {
+			t.Errorf("Validate(%s) error = %v, want valid %v", tt.input, err, tt.valid)
+		}
+	}
+}
+
+func TestReflectErrors(t *testing.T) {
+	if _, err := jsonschema.Reflect(nil); err == nil {
+		t.Error("expected error for nil")
+	}
+	if _, err := jsonschema.Reflect(struct{ C chan int }{}); err == nil {
+		t.Error("expected error for chan field")
+	}
+	if _, err := jsonschema.Reflect(struct {
+		A string `jsonschema:"minimum=one"`
+	}{}); err == nil {
+		t.Error("expected error for invalid tag option")
+	}
+	if _, err := jsonschema.Reflect(struct {
+		A string `jsonschema:"title=A"`
+	}{}); err == nil {
+		t.Error("expected error for unknown tag option")
+	}
+	if _, err := jsonschema.Reflect(struct {
+		A int `jsonschema:"enum=one"`
+	}{}); err == nil {
+		t.Error("expected error for invalid enum")
+	}
+}
@@ -0,0 +1,406 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxRefDepth limits the chain of references followed without descending into the value,
+// to stop on schemas referencing themselves.
+const maxRefDepth = 32
+
+var (
+	identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	emailRegexp      = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	uuidRegexp       = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// formatCheckers validates the common string formats, other formats are not checked.
+var formatCheckers = map[string]func(string) bool{
+	"date-time": func(s string) bool {
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	},
+	"date": func(s string) bool {
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	},
+	"time": func(s string) bool {
+		_, err := time.Parse("15:04:05Z07:00", s)
+		return err == nil
+	},
+	"email": emailRegexp.MatchString,
+	"uuid":  uuidRegexp.MatchString,
+	"uri": func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	},
+}
+
+// ValidationError is a value not matching its schema, at the JSON path Path, e.g. "$.items[2].name".
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors is returned by Definition.Validate with every mismatch found.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks the JSON data against the schema: types, required and additional properties,
+// enums, const, array items, nested properties, anyOf/oneOf/allOf and the string, number and
+// array bounds. The formats date-time, date, time, email, uuid and uri are checked. A mismatch is reported as ValidationErrors,
+// and data that is not valid JSON as a plain error.
+func (d Definition) Validate(data json.RawMessage) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("jsonschema: invalid JSON: %w", err)
+	}
+	if dec.More() {
+		return fmt.Errorf("jsonschema: invalid JSON: unexpected data after top-level value")
+	}
+
+	v := validator{root: &d}
+	v.validate(&d, value, "$", 0)
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+type validator struct {
+	root *Definition
+	errs ValidationErrors
+}
+
+func (v *validator) errorf(path, format string, args ...any) {
+	v.errs = append(v.errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate(def *Definition, value any, path string, refDepth int) {
+	if def.Ref != "" {
+		if refDepth >= maxRefDepth {
+			v.errorf(path, "too many nested references %q", def.Ref)
+			return
+		}
+		target, ok := v.resolve(def.Ref)
+		if !ok {
+			v.errorf(path, "unknown reference %q", def.Ref)
+			return
+		}
+		v.validate(target, value, path, refDepth+1)
+		return
+	}
+
+	if value == nil && def.Nullable {
+		return
+	}
+	if def.Type != "" && !matchesType(def.Type, value) {
+		v.errorf(path, "expected %s, got %s", def.Type, typeOf(value))
+		return
+	}
+
+	if len(def.EnumValues) > 0 {
+		if !slices.ContainsFunc(def.EnumValues, func(e any) bool { return jsonEqual(value, e) }) {
+			v.errorf(path, "value %s is not one of %s", formatValue(value), formatValue(def.EnumValues))
+		}
+	} else if len(def.Enum) > 0 {
+		// the values of Enum are JSON strings, a number or bool never matches them
+		if !slices.ContainsFunc(def.Enum, func(e string) bool { return jsonEqual(value, e) }) {
+			v.errorf(path, "value %s is not one of %q", formatValue(value), def.Enum)
+		}
+	}
+	if def.Const != nil && !jsonEqual(value, def.Const) {
+		v.errorf(path, "value %s is not %s", formatValue(value), formatValue(def.Const))
+	}
+
+	v.validateComposition(def, value, path, refDepth)
+
+	switch value := value.(type) {
+	case map[string]any:
+		v.validateObject(def, value, path)
+	case []any:
+		if def.MinItems != nil && len(value) < *def.MinItems {
+			v.errorf(path, "expected at least %d items, got %d", *def.MinItems, len(value))
+		}
+		if def.MaxItems != nil && len(value) > *def.MaxItems {
+			v.errorf(path, "expected at most %d items, got %d", *def.MaxItems, len(value))
+		}
+		if def.Items != nil {
+			for i, item := range value {
+				v.validate(def.Items, item, path+"["+strconv.Itoa(i)+"]", 0)
+			}
+		}
+	case string:
+		v.validateString(def, value, path)
+	case json.Number:
+		f, err := value.Float64()
+		if err != nil {
+			v.errorf(path, "invalid number %s", value)
+			return
+		}
+		if def.Minimum != nil && f < *def.Minimum {
+			v.errorf(path, "value %s is less than the minimum %v", value, *def.Minimum)
+		}
+		if def.Maximum != nil && f > *def.Maximum {
+			v.errorf(path, "value %s is greater than the maximum %v", value, *def.Maximum)
+		}
+	}
+}
+
+// validateComposition checks the anyOf, oneOf and allOf schemas. When no anyOf or oneOf schema
+// matches, the mismatches of the first schema of the right type are reported, e.g. the object
+// schema of a nullable object, otherwise they are summarized.
+func (v *validator) validateComposition(def *Definition, value any, path string, refDepth int) {
+	if len(def.AnyOf) > 0 {
+		if n, closest := v.matchAll(def.AnyOf, value, path, refDepth); n == 0 {
+			v.reportClosest(closest, path, "value does not match any of the anyOf schemas")
+		}
+	}
+	if len(def.OneOf) > 0 {
+		n, closest := v.matchAll(def.OneOf, value, path, refDepth)
+		switch n {
+		case 0:
+			v.reportClosest(closest, path, "value does not match any of the oneOf schemas")
+		case 1:
+		default:
+			v.errorf(path, "value matches %d of the oneOf schemas, expected exactly 1", n)
+		}
+	}
+	for i := range def.AllOf {
+		v.validate(&def.AllOf[i], value, path, refDepth)
+	}
+}
+
+// matchAll returns the number of schemas the value matches, and the mismatches of
+// the first schema whose type matched.
+func (v *validator) matchAll(defs []Definition, value any, path string, refDepth int) (int, ValidationErrors) {
+	n := 0
+	var closest ValidationErrors
+	for i := range defs {
+		sub := validator{root: v.root}
+		sub.validate(&defs[i], value, path, refDepth)
+		if len(sub.errs) == 0 {
+			n++
+			continue
+		}
+		if closest == nil && !(len(sub.errs) == 1 && sub.errs[0].Path == path) {
+			closest = sub.errs
+		}
+	}
+	return n, closest
+}
+
+func (v *validator) reportClosest(closest ValidationErrors, path, msg string) {
+	if closest != nil {
+		v.errs = append(v.errs, closest...)
+		return
+	}
+	v.errorf(path, "%s", msg)
+}
+
+func (v *validator) validateObject(def *Definition, value map[string]any, path string) {
+	for _, name := range def.Required {
+		if _, ok := value[name]; !ok {
+			v.errorf(childPath(path, name), "required")
+		}
+	}
+
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if prop, ok := def.Properties[name]; ok {
+			v.validate(&prop, value[name], childPath(path, name), 0)
+			continue
+		}
+
+		switch additional := def.AdditionalProperties.(type) {
+		case bool:
+			if !additional {
+				v.errorf(childPath(path, name), "additional property not allowed")
+			}
+		case Definition:
+			v.validate(&additional, value[name], childPath(path, name), 0)
+		case *Definition:
+			if additional != nil {
+				v.validate(additional, value[name], childPath(path, name), 0)
+			}
+		}
+	}
+}
+
+func (v *validator) validateString(def *Definition, value string, path string) {
+	length := utf8.RuneCountInString(value)
+	if def.MinLength != nil && length < *def.MinLength {
+		v.errorf(path, "expected at least %d characters, got %d", *def.MinLength, length)
+	}
+	if def.MaxLength != nil && length > *def.MaxLength {
+		v.errorf(path, "expected at most %d characters, got %d", *def.MaxLength, length)
+	}
+	if def.Pattern != "" {
+		re, err := regexp.Compile(def.Pattern)
+		if err != nil {
+			v.errorf(path, "invalid pattern %q: %v", def.Pattern, err)
+		} else if !re.MatchString(value) {
+			v.errorf(path, "value %q does not match the pattern %q", value, def.Pattern)
+		}
+	}
+	if check, ok := formatCheckers[def.Format]; ok && !check(value) {
+		v.errorf(path, "value %q is not a valid %s", value, def.Format)
+	}
+}
+
+// resolve returns the definition referenced by ref, "#" or "#/$defs/<name>" of the root schema.
+func (v *validator) resolve(ref string) (*Definition, bool) {
+	if ref == "#" {
+		return v.root, true
+	}
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return nil, false
+	}
+	def, ok := v.root.Defs[name]
+	return &def, ok
+}
+
+func matchesType(t DataType, value any) bool {
+	switch t {
+	case Object:
+		_, ok := value.(map[string]any)
+		return ok
+	case Array:
+		_, ok := value.([]any)
+		return ok
+	case String:
+		_, ok := value.(string)
+		return ok
+	case Boolean:
+		_, ok := value.(bool)
+		return ok
+	case Number:
+		_, ok := value.(json.Number)
+		return ok
+	case Integer:
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	case Null:
+		return value == nil
+	default:
+		return true
+	}
+}
+
+func typeOf(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return string(Object)
+	case []any:
+		return string(Array)
+	case string:
+		return string(String)
+	case bool:
+		return string(Boolean)
+	case json.Number:
+		return string(Number)
+	case nil:
+		return string(Null)
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// jsonEqual compares decoded JSON values, numbers by their value.
+func jsonEqual(a, b any) bool {
+	bs, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+	if err := dec.Decode(&b); err != nil {
+		return false
+	}
+	return equalValues(a, b)
+}
+
+func equalValues(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fa, errA := a.Float64()
+		fb, errB := b.Float64()
+		return errA == nil && errB == nil && fa == fb
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, va := range a {
+			vb, ok := b[k]
+			if !ok || !equalValues(va, vb) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalValues(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+func formatValue(value any) string {
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(bs)
+}
+
+func childPath(path, name string) string {
+	if identifierRegexp.MatchString(name) {
+		return path + "." + name
+	}
+	return path + "[" + strconv.Quote(name) + "]"
+}
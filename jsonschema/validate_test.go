@@ -0,0 +1,234 @@
+package jsonschema_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/liushuangls/go-anthropic/v2/jsonschema"
+)
+
+func TestDefinition_Validate(t *testing.T) {
+	itemDef := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"name":     {Type: jsonschema.String},
+			"quantity": {Type: jsonschema.Integer},
+			"unit":     {Type: jsonschema.String, Enum: []string{"kg", "g"}},
+			"note":     {Type: jsonschema.String, Nullable: true},
+		},
+		Required: []string{"name"},
+	}
+	def := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"items":      {Type: jsonschema.Array, Items: &itemDef},
+			"price":      {Type: jsonschema.Number},
+			"paid":       {Type: jsonschema.Boolean},
+			"extra info": {Type: jsonschema.String},
+			"metadata":   {},
+		},
+		Required: []string{"items"},
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "Test with valid data",
+			data: `{"items":[{"name":"apple","quantity":2,"unit":"kg","note":null}],"price":1.5,"paid":true,"metadata":[1,"a"]}`,
+		},
+		{
+			name: "Test with integral number as integer",
+			data: `{"items":[{"name":"apple","quantity":2.0}]}`,
+		},
+		{
+			name: "Test with missing required fields",
+			data: `{"items":[{"name":"apple"},{"name":"pear"},{"quantity":1}]}`,
+			want: []string{"$.items[2].name: required"},
+		},
+		{
+			name: "Test with wrong types",
+			data: `{"items":[{"name":1,"quantity":1.5}],"price":"1","paid":null,"extra info":false}`,
+			want: []string{
+				`$["extra info"]: expected string, got boolean`,
+				`$.items[0].name: expected string, got number`,
+				`$.items[0].quantity: expected integer, got number`,
+				`$.paid: expected boolean, got null`,
+				`$.price: expected number, got string`,
+			},
+		},
+		{
+			name: "Test with enum mismatch",
+			data: `{"items":[{"name":"apple","unit":"lb"}]}`,
+			want: []string{`$.items[0].unit: value "lb" is not one of ["kg" "g"]`},
+		},
+		{
+			name: "Test with wrong root type",
+			data: `[]`,
+			want: []string{"$: expected object, got array"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := def.Validate(json.RawMessage(tt.data))
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var errs jsonschema.ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Validate() error = %v, want ValidationErrors", err)
+			}
+			if len(errs) != len(tt.want) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.want)
+			}
+			for i, want := range tt.want {
+				if errs[i].Error() != want {
+					t.Errorf("Validate() error[%d] = %q, want %q", i, errs[i].Error(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestDefinition_ValidateRefs(t *testing.T) {
+	type node struct {
+		Value    string  `json:"value" jsonschema:"required"`
+		Children []*node `json:"children"`
+	}
+	def, err := jsonschema.Reflect(node{})
+	if err != nil {
+		t.Fatalf("Reflect() error = %v", err)
+	}
+
+	err = def.Validate(json.RawMessage(`{"value":"a","children":[{"value":"b","children":[{"children":[]}]}]}`))
+	if err == nil || err.Error() != "$.children[0].children[0].value: required" {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	loop := jsonschema.Definition{Ref: "#"}
+	if err := loop.Validate(json.RawMessage(`{}`)); err == nil {
+		t.Fatal("expected error for self referencing schema")
+	}
+}
+
+func TestDefinition_ValidateInvalidJSON(t *testing.T) {
+	def := jsonschema.Definition{Type: jsonschema.Object}
+	err := def.Validate(json.RawMessage(`{"a":`))
+	var errs jsonschema.ValidationErrors
+	if err == nil || errors.As(err, &errs) {
+		t.Fatalf("expected invalid JSON error, got %v", err)
+	}
+}
+
+func TestDefinition_ValidateKeywords(t *testing.T) {
+	one, ten := 1.0, 10.0
+	minItems, two, three := 1, 2, 3
+	def := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"code":    {Type: jsonschema.String, Pattern: "^[a-z]+$", MinLength: &two, MaxLength: &three},
+			"count":   {Type: jsonschema.Integer, Minimum: &one, Maximum: &ten},
+			"tags":    {Type: jsonschema.Array, MinItems: &minItems, MaxItems: &two},
+			"kind":    {Const: "user"},
+			"level":   {Enum: []string{"1", "true"}},
+			"created": {Type: jsonschema.String, Format: "date-time"},
+			"id": {
+				AnyOf: []jsonschema.Definition{{Type: jsonschema.String, Format: "uuid"}, {Type: jsonschema.Integer}},
+			},
+			"shape": {
+				OneOf: []jsonschema.Definition{
+					{Type: jsonschema.Object, Properties: map[string]jsonschema.Definition{"radius": {Type: jsonschema.Number}}, Required: []string{"radius"}},
+					{Type: jsonschema.Object, Properties: map[string]jsonschema.Definition{"side": {Type: jsonschema.Number}}, Required: []string{"side"}},
+				},
+			},
+			"labels": {Type: jsonschema.Object, AdditionalProperties: jsonschema.Definition{Type: jsonschema.String}},
+		},
+		AdditionalProperties: false,
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "Test with valid data",
+			data: `{"code":"ab","count":10,"tags":["a"],"kind":"user","created":"2024-10-22T10:00:00Z",` +
+				`"id":"123e4567-e89b-12d3-a456-426614174000","shape":{"radius":1},"labels":{"a":"b"},"level":"1"}`,
+		},
+		{
+			name: "Test with out of bounds values",
+			data: `{"code":"abcd","count":0,"tags":[]}`,
+			want: []string{
+				"$.code: expected at most 3 characters, got 4",
+				"$.count: value 0 is less than the minimum 1",
+				"$.tags: expected at least 1 items, got 0",
+			},
+		},
+		{
+			name: "Test with pattern, const and format mismatch",
+			data: `{"code":"A1","kind":"admin","created":"yesterday"}`,
+			want: []string{
+				`$.code: value "A1" does not match the pattern "^[a-z]+$"`,
+				`$.created: value "yesterday" is not a valid date-time`,
+				`$.kind: value "admin" is not "user"`,
+			},
+		},
+		{
+			name: "Test with untyped string enum",
+			data: `{"level":1}`,
+			want: []string{`$.level: value 1 is not one of ["1" "true"]`},
+		},
+		{
+			name: "Test with untyped string enum of a bool",
+			data: `{"level":true}`,
+			want: []string{`$.level: value true is not one of ["1" "true"]`},
+		},
+		{
+			name: "Test with composition mismatch",
+			data: `{"id":true,"shape":{"radius":1,"side":1}}`,
+			want: []string{
+				"$.id: value does not match any of the anyOf schemas",
+				"$.shape: value matches 2 of the oneOf schemas, expected exactly 1",
+			},
+		},
+		{
+			name: "Test with additional properties",
+			data: `{"labels":{"a":1},"other":1}`,
+			want: []string{
+				"$.labels.a: expected string, got number",
+				"$.other: additional property not allowed",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := def.Validate(json.RawMessage(tt.data))
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var errs jsonschema.ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != len(tt.want) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.want)
+			}
+			for i, want := range tt.want {
+				if errs[i].Error() != want {
+					t.Errorf("Validate() error[%d] = %q, want %q", i, errs[i].Error(), want)
+				}
+			}
+		})
+	}
+}
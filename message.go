@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"slices"
 )
 
 type MessagesResponseType string
@@ -22,6 +23,23 @@ const (
 	MessagesContentTypeToolResult     MessagesContentType = "tool_result"
 	MessagesContentTypeToolUse        MessagesContentType = "tool_use"
 	MessagesContentTypeInputJsonDelta MessagesContentType = "input_json_delta"
+
+	MessagesContentTypeThinking         MessagesContentType = "thinking"
+	MessagesContentTypeThinkingDelta    MessagesContentType = "thinking_delta"
+	MessagesContentTypeSignatureDelta   MessagesContentType = "signature_delta"
+	MessagesContentTypeRedactedThinking MessagesContentType = "redacted_thinking"
+
+	MessagesContentTypeDocument       MessagesContentType = "document"
+	MessagesContentTypeCitationsDelta MessagesContentType = "citations_delta"
+)
+
+type MessagesContentSourceType string
+
+const (
+	MessagesContentSourceTypeBase64  MessagesContentSourceType = "base64"
+	MessagesContentSourceTypeText    MessagesContentSourceType = "text"
+	MessagesContentSourceTypeContent MessagesContentSourceType = "content"
+	MessagesContentSourceTypeURL     MessagesContentSourceType = "url"
 )
 
 type MessagesStopReason string
@@ -39,7 +57,11 @@ type MessagesRequest struct {
 	Messages         []Message `json:"messages"`
 	MaxTokens        int       `json:"max_tokens"`
 
-	System        string           `json:"system,omitempty"`
+	System string `json:"system,omitempty"`
+	// MultiSystem sends the system prompt as a list of text blocks, e.g. to set cache_control.
+	// It takes precedence over System.
+	MultiSystem []MessageSystemPart `json:"-"`
+
 	Metadata      map[string]any   `json:"metadata,omitempty"`
 	StopSequences []string         `json:"stop_sequences,omitempty"`
 	Stream        bool             `json:"stream,omitempty"`
@@ -48,10 +70,77 @@ type MessagesRequest struct {
 	TopK          *int             `json:"top_k,omitempty"`
 	Tools         []ToolDefinition `json:"tools,omitempty"`
 	ToolChoice    *ToolChoice      `json:"tool_choice,omitempty"`
+	Thinking      *Thinking        `json:"thinking,omitempty"`
 }
 
 var _ VertexAISupport = (*MessagesRequest)(nil)
 
+func (m MessagesRequest) MarshalJSON() ([]byte, error) {
+	type alias MessagesRequest
+	return json.Marshal(struct {
+		System any `json:"system,omitempty"`
+		alias
+	}{
+		System: m.system(),
+		alias:  alias(m),
+	})
+}
+
+func (m *MessagesRequest) UnmarshalJSON(data []byte) error {
+	type alias MessagesRequest
+	aux := struct {
+		System json.RawMessage `json:"system,omitempty"`
+		*alias
+	}{
+		alias: (*alias)(m),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	switch {
+	case len(aux.System) == 0 || string(aux.System) == "null":
+		return nil
+	case aux.System[0] == '"':
+		return json.Unmarshal(aux.System, &m.System)
+	default:
+		return json.Unmarshal(aux.System, &m.MultiSystem)
+	}
+}
+
+// system returns the system prompt in the form sent to the API.
+func (m MessagesRequest) system() any {
+	if len(m.MultiSystem) > 0 {
+		return m.MultiSystem
+	}
+	if m.System != "" {
+		return m.System
+	}
+	return nil
+}
+
+// hasCacheControl reports whether any system part, tool or content block of the request sets cache_control.
+func (m MessagesRequest) hasCacheControl() bool {
+	for _, part := range m.MultiSystem {
+		if part.CacheControl != nil {
+			return true
+		}
+	}
+	for _, tool := range m.Tools {
+		if tool.CacheControl != nil {
+			return true
+		}
+	}
+	for _, message := range m.Messages {
+		for _, content := range message.Content {
+			if content.CacheControl != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (m MessagesRequest) GetModel() string {
 	return m.Model
 }
@@ -73,6 +162,54 @@ func (m *MessagesRequest) SetTopK(k int) {
 	m.TopK = &k
 }
 
+type ThinkingType string
+
+const (
+	ThinkingTypeEnabled  ThinkingType = "enabled"
+	ThinkingTypeDisabled ThinkingType = "disabled"
+)
+
+// Thinking configures extended thinking.
+// docs: https://docs.anthropic.com/en/docs/build-with-claude/extended-thinking
+type Thinking struct {
+	Type ThinkingType `json:"type"`
+	// BudgetTokens is the number of tokens Claude may use for its internal reasoning.
+	// It must be at least 1024 and less than MaxTokens.
+	BudgetTokens int `json:"budget_tokens,omitempty"`
+}
+
+func NewThinking(budgetTokens int) *Thinking {
+	return &Thinking{
+		Type:         ThinkingTypeEnabled,
+		BudgetTokens: budgetTokens,
+	}
+}
+
+type CacheControlType string
+
+const (
+	CacheControlTypeEphemeral CacheControlType = "ephemeral"
+)
+
+// MessageCacheControl marks the end of a cacheable prompt prefix.
+// docs: https://docs.anthropic.com/en/docs/build-with-claude/prompt-caching
+type MessageCacheControl struct {
+	Type CacheControlType `json:"type"`
+}
+
+type MessageSystemPart struct {
+	Type         string               `json:"type"`
+	Text         string               `json:"text"`
+	CacheControl *MessageCacheControl `json:"cache_control,omitempty"`
+}
+
+func NewMessageSystemPart(text string) MessageSystemPart {
+	return MessageSystemPart{
+		Type: "text",
+		Text: text,
+	}
+}
+
 type Message struct {
 	Role    string           `json:"role"`
 	Content []MessageContent `json:"content"`
@@ -111,13 +248,30 @@ type MessageContent struct {
 
 	Text *string `json:"text,omitempty"`
 
-	Source *MessageContentImageSource `json:"source,omitempty"`
+	Source *MessageContentSource `json:"source,omitempty"`
+
+	// Title and Context are optional on document blocks, Context is not cited.
+	Title   *string `json:"title,omitempty"`
+	Context *string `json:"context,omitempty"`
+
+	// Citations enables citations on document blocks, and holds the citations of text blocks in responses.
+	Citations *MessageContentCitations `json:"citations,omitempty"`
+	// Citation is set on citations_delta blocks.
+	Citation *Citation `json:"citation,omitempty"`
 
 	*MessageContentToolResult
 
 	*MessageContentToolUse
 
 	PartialJson *string `json:"partial_json,omitempty"`
+
+	// Thinking and Signature are set on thinking blocks, Data on redacted_thinking blocks.
+	// They must be sent back unmodified in the following turns of a conversation.
+	Thinking  *string `json:"thinking,omitempty"`
+	Signature *string `json:"signature,omitempty"`
+	Data      *string `json:"data,omitempty"`
+
+	CacheControl *MessageCacheControl `json:"cache_control,omitempty"`
 }
 
 func NewTextMessageContent(text string) MessageContent {
@@ -127,13 +281,35 @@ func NewTextMessageContent(text string) MessageContent {
 	}
 }
 
-func NewImageMessageContent(source MessageContentImageSource) MessageContent {
+func NewImageMessageContent(source MessageContentSource) MessageContent {
 	return MessageContent{
 		Type:   MessagesContentTypeImage,
 		Source: &source,
 	}
 }
 
+func NewDocumentMessageContent(source MessageContentSource) MessageContent {
+	return MessageContent{
+		Type:   MessagesContentTypeDocument,
+		Source: &source,
+	}
+}
+
+func NewThinkingMessageContent(thinking, signature string) MessageContent {
+	return MessageContent{
+		Type:      MessagesContentTypeThinking,
+		Thinking:  &thinking,
+		Signature: &signature,
+	}
+}
+
+func NewRedactedThinkingMessageContent(data string) MessageContent {
+	return MessageContent{
+		Type: MessagesContentTypeRedactedThinking,
+		Data: &data,
+	}
+}
+
 func NewToolResultMessageContent(toolUseID, content string, isError bool) MessageContent {
 	return MessageContent{
 		Type:                     MessagesContentTypeToolResult,
@@ -159,12 +335,25 @@ func (m *MessageContent) GetText() string {
 	return ""
 }
 
+func (m *MessageContent) GetThinking() string {
+	if m.Thinking != nil {
+		return *m.Thinking
+	}
+	return ""
+}
+
+func (m *MessageContent) GetSignature() string {
+	if m.Signature != nil {
+		return *m.Signature
+	}
+	return ""
+}
+
 func (m *MessageContent) ConcatText(s string) {
-	if m.Text == nil {
-		m.Text = &s
-	} else {
-		*m.Text += s
+	if m.Text != nil {
+		s = *m.Text + s
 	}
+	m.Text = &s
 }
 
 func (m *MessageContent) MergeContentDelta(mc MessageContent) {
@@ -182,15 +371,38 @@ func (m *MessageContent) MergeContentDelta(mc MessageContent) {
 			ID:   mc.MessageContentToolUse.ID,
 			Name: mc.MessageContentToolUse.Name,
 		}
+	case MessagesContentTypeThinking, MessagesContentTypeThinkingDelta:
+		m.Thinking = concatString(m.Thinking, mc.Thinking)
+	case MessagesContentTypeSignatureDelta:
+		m.Signature = concatString(m.Signature, mc.Signature)
+	case MessagesContentTypeRedactedThinking:
+		m.Data = mc.Data
 	case MessagesContentTypeInputJsonDelta:
-		if m.PartialJson == nil {
-			m.PartialJson = mc.PartialJson
-		} else {
-			*m.PartialJson += *mc.PartialJson
+		m.PartialJson = concatString(m.PartialJson, mc.PartialJson)
+	case MessagesContentTypeCitationsDelta:
+		if mc.Citation != nil {
+			citations := &MessageContentCitations{}
+			if m.Citations != nil {
+				citations.Items = slices.Clone(m.Citations.Items)
+			}
+			citations.Items = append(citations.Items, *mc.Citation)
+			m.Citations = citations
 		}
 	}
 }
 
+// concatString returns a pointer to the concatenation of a and b, without modifying a.
+func concatString(a, b *string) *string {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	s := *a + *b
+	return &s
+}
+
 type MessageContentToolResult struct {
 	ToolUseID *string          `json:"tool_use_id,omitempty"`
 	Content   []MessageContent `json:"content,omitempty"`
@@ -210,10 +422,145 @@ func NewMessageContentToolResult(toolUseID, content string, isError bool) *Messa
 	}
 }
 
-type MessageContentImageSource struct {
-	Type      string `json:"type"`
-	MediaType string `json:"media_type"`
-	Data      any    `json:"data"`
+// MessageContentSource is the source of an image or document block.
+type MessageContentSource struct {
+	Type      MessagesContentSourceType `json:"type"`
+	MediaType string                    `json:"media_type,omitempty"`
+	// Data is the base64 data of a base64 source, or the text of a text source.
+	// A []byte is encoded to base64.
+	Data any `json:"data,omitempty"`
+	// URL is the URL of an url source.
+	URL string `json:"url,omitempty"`
+	// Content holds the text and image blocks of a content source.
+	Content []MessageContent `json:"content,omitempty"`
+}
+
+// Deprecated: use MessageContentSource.
+type MessageContentImageSource = MessageContentSource
+
+// NewPDFSource returns a document source with base64 encoded PDF data.
+func NewPDFSource(data string) MessageContentSource {
+	return MessageContentSource{
+		Type:      MessagesContentSourceTypeBase64,
+		MediaType: "application/pdf",
+		Data:      data,
+	}
+}
+
+// NewPlainTextSource returns a document source with plain text, cited by character ranges.
+func NewPlainTextSource(text string) MessageContentSource {
+	return MessageContentSource{
+		Type:      MessagesContentSourceTypeText,
+		MediaType: "text/plain",
+		Data:      text,
+	}
+}
+
+// NewContentSource returns a document source with custom content, cited by block ranges.
+func NewContentSource(content ...MessageContent) MessageContentSource {
+	return MessageContentSource{
+		Type:    MessagesContentSourceTypeContent,
+		Content: content,
+	}
+}
+
+// NewURLSource returns an image or document source fetched from the URL.
+func NewURLSource(url string) MessageContentSource {
+	return MessageContentSource{
+		Type: MessagesContentSourceTypeURL,
+		URL:  url,
+	}
+}
+
+// MessageContentCitations is marshaled as {"enabled": true} to enable the citations of a document,
+// or as the array of citations of a text block if Items is not nil.
+type MessageContentCitations struct {
+	Enabled bool
+	Items   []Citation
+}
+
+func (c MessageContentCitations) MarshalJSON() ([]byte, error) {
+	if c.Items != nil {
+		return json.Marshal(c.Items)
+	}
+	return json.Marshal(struct {
+		Enabled bool `json:"enabled"`
+	}{c.Enabled})
+}
+
+func (c *MessageContentCitations) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '[' {
+		c.Enabled = false
+		c.Items = []Citation{}
+		return json.Unmarshal(data, &c.Items)
+	}
+
+	var v struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c.Enabled, c.Items = v.Enabled, nil
+	return nil
+}
+
+type CitationType string
+
+const (
+	CitationTypeCharLocation         CitationType = "char_location"
+	CitationTypePageLocation         CitationType = "page_location"
+	CitationTypeContentBlockLocation CitationType = "content_block_location"
+)
+
+// Citation is the location in a document of a text block's cited text.
+// Only the location fields of its Type are set: character indexes in plain text documents,
+// page numbers in PDF documents and block indexes in custom content documents.
+type Citation struct {
+	Type          CitationType `json:"type"`
+	CitedText     string       `json:"cited_text"`
+	DocumentIndex int          `json:"document_index"`
+	DocumentTitle *string      `json:"document_title"`
+
+	StartCharIndex  int `json:"start_char_index"`
+	EndCharIndex    int `json:"end_char_index"`
+	StartPageNumber int `json:"start_page_number"`
+	EndPageNumber   int `json:"end_page_number"`
+	StartBlockIndex int `json:"start_block_index"`
+	EndBlockIndex   int `json:"end_block_index"`
+}
+
+func (c Citation) MarshalJSON() ([]byte, error) {
+	type citation struct {
+		Type          CitationType `json:"type"`
+		CitedText     string       `json:"cited_text"`
+		DocumentIndex int          `json:"document_index"`
+		DocumentTitle *string      `json:"document_title"`
+	}
+	base := citation{c.Type, c.CitedText, c.DocumentIndex, c.DocumentTitle}
+
+	switch c.Type {
+	case CitationTypeCharLocation:
+		return json.Marshal(struct {
+			citation
+			StartCharIndex int `json:"start_char_index"`
+			EndCharIndex   int `json:"end_char_index"`
+		}{base, c.StartCharIndex, c.EndCharIndex})
+	case CitationTypePageLocation:
+		return json.Marshal(struct {
+			citation
+			StartPageNumber int `json:"start_page_number"`
+			EndPageNumber   int `json:"end_page_number"`
+		}{base, c.StartPageNumber, c.EndPageNumber})
+	case CitationTypeContentBlockLocation:
+		return json.Marshal(struct {
+			citation
+			StartBlockIndex int `json:"start_block_index"`
+			EndBlockIndex   int `json:"end_block_index"`
+		}{base, c.StartBlockIndex, c.EndBlockIndex})
+	default:
+		return json.Marshal(base)
+	}
 }
 
 type MessageContentToolUse struct {
@@ -247,9 +594,22 @@ func (m MessagesResponse) GetFirstContentText() string {
 	return m.Content[0].GetText()
 }
 
+// ToMessage returns the response as an assistant message, with the content blocks
+// unmodified so that thinking blocks can be passed back in multi-turn conversations.
+func (m MessagesResponse) ToMessage() Message {
+	return Message{
+		Role:    RoleAssistant,
+		Content: m.Content,
+	}
+}
+
 type MessagesUsage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
+	// The number of tokens written to the cache when creating a new entry.
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	// The number of tokens retrieved from the cache for this request.
+	CacheReadInputTokens int `json:"cache_read_input_tokens,omitempty"`
 }
 
 type ToolDefinition struct {
@@ -261,25 +621,30 @@ type ToolDefinition struct {
 	// The jsonschema package is provided for convenience, but you should
 	// consider another specialized library if you require more complex schemas.
 	InputSchema any `json:"input_schema"`
+
+	CacheControl *MessageCacheControl `json:"cache_control,omitempty"`
 }
 
 type ToolChoice struct {
 	// oneof: auto(default) any tool
 	Type string `json:"type"`
 	Name string `json:"name,omitempty"`
+	// DisableParallelToolUse makes the model use at most one tool, or exactly one with the any and tool types.
+	DisableParallelToolUse *bool `json:"disable_parallel_tool_use,omitempty"`
 }
 
 func (c *Client) CreateMessages(ctx context.Context, request MessagesRequest) (response MessagesResponse, err error) {
 	request.Stream = false
-
-	var setters []requestSetter
-	if len(request.Tools) > 0 {
-		setters = append(setters, withBetaVersion(c.config.BetaVersion))
+	setters := c.messagesRequestSetters(&request)
+	if err = c.config.ModelRegistry.checkMaxTokens(&request, betaVersions(setters)); err != nil {
+		return
 	}
 
 	urlSuffix := "/messages"
 	if c.IsVertexAI() {
 		urlSuffix = ":rawPredict"
+	} else if c.IsBedrock() {
+		urlSuffix = "/invoke"
 	}
 
 	req, err := c.newRequest(ctx, http.MethodPost, urlSuffix, &request, setters...)
@@ -290,3 +655,17 @@ func (c *Client) CreateMessages(ctx context.Context, request MessagesRequest) (r
 	err = c.sendRequest(req, &response)
 	return
 }
+
+// messagesRequestSetters returns the beta headers needed by the features the request uses.
+// The client's BetaVersion is sent if the request uses tools or it is not the default tools beta,
+// e.g. to raise the maximum output tokens.
+func (c *Client) messagesRequestSetters(request *MessagesRequest) []requestSetter {
+	var setters []requestSetter
+	if betaVersion := c.config.BetaVersion; len(request.Tools) > 0 || betaVersion != "" && betaVersion != BetaTools20240516 {
+		setters = append(setters, withBetaVersion(betaVersion))
+	}
+	if request.hasCacheControl() {
+		setters = append(setters, withBetaVersion(BetaPromptCaching20240731))
+	}
+	return setters
+}
@@ -1,19 +1,19 @@
 package anthropic
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"slices"
+	"strings"
+
+	"github.com/liushuangls/go-anthropic/v2/internal/partialjson"
+	"github.com/liushuangls/go-anthropic/v2/internal/sse"
 )
 
 var (
-	eventPrefix                   = []byte("event:")
-	dataPrefix                    = []byte("data:")
 	ErrTooManyEmptyStreamMessages = errors.New("stream has sent too many empty messages")
 )
 
@@ -44,6 +44,11 @@ type MessagesStreamRequest struct {
 	OnContentBlockStop  func(MessagesEventContentBlockStopData, MessageContent) `json:"-"`
 	OnMessageDelta      func(MessagesEventMessageDeltaData)                     `json:"-"`
 	OnMessageStop       func(MessagesEventMessageStopData)                      `json:"-"`
+
+	// OnToolInputDelta is called on each input_json_delta with a best-effort decoding
+	// of the input of the tool_use block at index streamed so far, see PartialToolInput.
+	// The input is decoded only when it is set, each delta being parsed once.
+	OnToolInputDelta func(index int, partial map[string]any) `json:"-"`
 }
 
 type MessagesEventMessageStartData struct {
@@ -82,161 +87,413 @@ type MessagesEventMessageStopData struct {
 	Type string `json:"type"`
 }
 
-func (c *Client) CreateMessagesStream(ctx context.Context, request MessagesStreamRequest) (response MessagesResponse, err error) {
+// MessagesStreamEvent is a single event of a messages stream, only the data field matching Type is set.
+type MessagesStreamEvent struct {
+	Type MessagesEvent
+
+	Error             *ErrorResponse
+	Ping              *MessagesEventPingData
+	MessageStart      *MessagesEventMessageStartData
+	ContentBlockStart *MessagesEventContentBlockStartData
+	ContentBlockDelta *MessagesEventContentBlockDeltaData
+	ContentBlockStop  *MessagesEventContentBlockStopData
+	MessageDelta      *MessagesEventMessageDeltaData
+	MessageStop       *MessagesEventMessageStopData
+
+	// Content is the finalized content block of a content_block_stop event,
+	// with the input of tool_use blocks decoded from the streamed partial JSON.
+	Content MessageContent
+}
+
+// MessagesStream reads the events of a streaming messages request one at a time
+// and accumulates them into the final message.
+type MessagesStream struct {
+	resp               *http.Response
+	decoder            streamDecoder
+	emptyMessagesLimit uint
+	emptyMessageCount  uint
+
+	message MessagesResponse
+	event   MessagesStreamEvent
+	err     error
+	closed  bool
+
+	// partialInputs holds the parsers of the partial inputs of the tool_use blocks by index
+	partialInputs map[int]*partialToolInput
+}
+
+// partialToolInput accumulates the partial input of a block, and parses its first length bytes.
+type partialToolInput struct {
+	// json holds the partial JSON, appending to it does not copy the JSON received before
+	json   strings.Builder
+	parser partialjson.Parser
+	length int
+	value  map[string]any
+	ok     bool
+}
+
+// streamDecoder reads the type and data of the events of a stream, an empty type being an unknown event.
+type streamDecoder interface {
+	next() (eventType string, data []byte, err error)
+}
+
+type sseStreamDecoder struct {
+	decoder *sse.Decoder
+}
+
+func (d sseStreamDecoder) next() (string, []byte, error) {
+	event, err := d.decoder.Next()
+	return event.Event, event.Data, err
+}
+
+// NewMessagesStream sends a streaming messages request. The events are read with Next and Event,
+// and the caller must Close the stream once done, which can be before the end of the stream.
+func (c *Client) NewMessagesStream(ctx context.Context, request MessagesRequest) (*MessagesStream, error) {
+	stream, err := c.newMessagesStream(ctx, &request)
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// newMessagesStream is like NewMessagesStream, but also returns the stream on error
+// so the response headers can be read from it.
+func (c *Client) newMessagesStream(ctx context.Context, request *MessagesRequest) (*MessagesStream, error) {
 	request.Stream = true
 
-	var setters []requestSetter
-	if len(request.Tools) > 0 {
-		setters = append(setters, withBetaVersion(c.config.BetaVersion))
+	stream := &MessagesStream{emptyMessagesLimit: c.config.EmptyMessagesLimit}
+	setters := c.messagesRequestSetters(request)
+	if err := c.config.ModelRegistry.checkMaxTokens(request, betaVersions(setters)); err != nil {
+		return stream, err
 	}
 
 	urlSuffix := "/messages"
 	if c.IsVertexAI() {
 		urlSuffix = ":streamRawPredict"
+	} else if c.IsBedrock() {
+		urlSuffix = "/invoke-with-response-stream"
 	}
 
-	req, err := c.newStreamRequest(ctx, http.MethodPost, urlSuffix, &request, setters...)
+	req, err := c.newStreamRequest(ctx, http.MethodPost, urlSuffix, request, setters...)
 	if err != nil {
-		return
+		return stream, err
 	}
 
-	resp, err := c.config.HTTPClient.Do(req)
+	// the first event is read before returning the stream, so that an error event
+	// sent in place of the message, such as overloaded_error, can be retried
+	var decoder *peekedStreamDecoder
+	resp, err := c.doRequest(req, &stream.message, func(resp *http.Response) error {
+		if c.IsBedrock() {
+			decoder = &peekedStreamDecoder{decoder: newBedrockStreamDecoder(resp)}
+		} else {
+			decoder = &peekedStreamDecoder{decoder: sseStreamDecoder{decoder: sse.NewDecoder(resp.Body)}}
+		}
+		return decoder.peek(resp)
+	})
 	if err != nil {
-		return
+		return stream, err
 	}
-	defer resp.Body.Close()
 
-	response.SetHeader(resp.Header)
+	stream.resp = resp
+	stream.decoder = decoder
+	return stream, nil
+}
+
+// peekedStreamDecoder is a streamDecoder whose first event is read ahead.
+type peekedStreamDecoder struct {
+	decoder   streamDecoder
+	peeked    bool
+	eventType string
+	data      []byte
+	err       error
+}
 
-	if err := c.handlerRequestError(resp); err != nil {
-		return response, err
+// peek reads the first event, and returns the error it holds if it is an error event.
+func (d *peekedStreamDecoder) peek(resp *http.Response) error {
+	d.eventType, d.data, d.err = d.decoder.next()
+	d.peeked = true
+
+	var e *Error
+	if errors.As(d.err, &e) {
+		return d.err
+	}
+	if MessagesEvent(d.eventType) == MessagesEventError {
+		var errRes ErrorResponse
+		if err := json.Unmarshal(d.data, &errRes); err == nil && errRes.Error != nil {
+			return newError(resp, d.data, errRes.Error)
+		}
+	}
+	return nil
+}
+
+func (d *peekedStreamDecoder) next() (string, []byte, error) {
+	if d.peeked {
+		d.peeked = false
+		return d.eventType, d.data, d.err
+	}
+	return d.decoder.next()
+}
+
+// Next reads the next event and merges it into the message. It returns false at the end of the stream,
+// after an error event, or if reading failed; Err tells these cases apart.
+func (s *MessagesStream) Next() bool {
+	if s.err != nil || s.closed || s.decoder == nil {
+		return false
 	}
 
-	reader := bufio.NewReader(resp.Body)
-	var (
-		event             []byte
-		emptyMessageCount uint
-	)
 	for {
-		rawLine, readErr := reader.ReadBytes('\n')
-		if readErr != nil {
-			if errors.Is(readErr, io.EOF) {
-				break
+		eventType, data, err := s.decoder.next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				s.err = err
 			}
-			return response, readErr
-		}
-
-		noSpaceLine := bytes.TrimSpace(rawLine)
-		if len(noSpaceLine) == 0 {
-			continue
-		}
-		if bytes.HasPrefix(noSpaceLine, eventPrefix) {
-			event = bytes.TrimSpace(bytes.TrimPrefix(noSpaceLine, eventPrefix))
-			continue
-		}
-		if bytes.HasPrefix(noSpaceLine, dataPrefix) {
-			var (
-				data      = bytes.TrimPrefix(noSpaceLine, dataPrefix)
-				eventType = MessagesEvent(event)
-			)
-			switch eventType {
-			case MessagesEventError:
-				var eventData ErrorResponse
-				if err := json.Unmarshal(data, &eventData); err != nil {
-					return response, err
-				}
-				if request.OnError != nil {
-					request.OnError(eventData)
-				}
-				return response, eventData.Error
-			case MessagesEventPing:
-				var d MessagesEventPingData
-				if err := json.Unmarshal(data, &d); err != nil {
-					return response, err
-				}
-				if request.OnPing != nil {
-					request.OnPing(d)
-				}
-				continue
-			case MessagesEventMessageStart:
-				var d MessagesEventMessageStartData
-				if err := json.Unmarshal(data, &d); err != nil {
-					return response, err
-				}
-				if request.OnMessageStart != nil {
-					request.OnMessageStart(d)
-				}
-				response = d.Message
-				continue
-			case MessagesEventContentBlockStart:
-				var d MessagesEventContentBlockStartData
-				if err := json.Unmarshal(data, &d); err != nil {
-					return response, err
-				}
-				if request.OnContentBlockStart != nil {
-					request.OnContentBlockStart(d)
-				}
-				response.Content = slices.Insert(response.Content, d.Index, d.ContentBlock)
-				continue
-			case MessagesEventContentBlockDelta:
-				var d MessagesEventContentBlockDeltaData
-				if err := json.Unmarshal(data, &d); err != nil {
-					return response, err
-				}
-				if request.OnContentBlockDelta != nil {
-					request.OnContentBlockDelta(d)
-				}
-				if len(response.Content)-1 < d.Index {
-					response.Content = slices.Insert(response.Content, d.Index, d.Delta)
-				} else {
-					response.Content[d.Index].MergeContentDelta(d.Delta)
-				}
-				continue
-			case MessagesEventContentBlockStop:
-				var d MessagesEventContentBlockStopData
-				if err := json.Unmarshal(data, &d); err != nil {
-					return response, err
-				}
-				var stopContent MessageContent
-				if len(response.Content) > d.Index {
-					stopContent = response.Content[d.Index]
-					if stopContent.Type == MessagesContentTypeToolUse {
-						stopContent.Input = json.RawMessage(*stopContent.PartialJson)
-						stopContent.PartialJson = nil
-						response.Content[d.Index] = stopContent
-					}
-				}
-				if request.OnContentBlockStop != nil {
-					request.OnContentBlockStop(d, stopContent)
-				}
-				continue
-			case MessagesEventMessageDelta:
-				var d MessagesEventMessageDeltaData
-				if err := json.Unmarshal(data, &d); err != nil {
-					return response, err
-				}
-				if request.OnMessageDelta != nil {
-					request.OnMessageDelta(d)
-				}
-				response.StopReason = d.Delta.StopReason
-				response.StopSequence = d.Delta.StopSequence
-				response.Usage.OutputTokens = d.Usage.OutputTokens
-				continue
-			case MessagesEventMessageStop:
-				var d MessagesEventMessageStopData
-				if err := json.Unmarshal(data, &d); err != nil {
-					return response, err
-				}
-				if request.OnMessageStop != nil {
-					request.OnMessageStop(d)
-				}
-				continue
+			return false
+		}
+
+		ok, err := s.handleEvent(MessagesEvent(eventType), data)
+		if err != nil {
+			s.err = err
+			return false
+		}
+		if ok {
+			return true
+		}
+
+		s.emptyMessageCount++
+		if s.emptyMessageCount > s.emptyMessagesLimit {
+			s.err = ErrTooManyEmptyStreamMessages
+			return false
+		}
+	}
+}
+
+// handleEvent decodes the event data and merges it into the message.
+// It returns false if the event type is unknown.
+func (s *MessagesStream) handleEvent(eventType MessagesEvent, data []byte) (bool, error) {
+	event := MessagesStreamEvent{Type: eventType}
+	switch eventType {
+	case MessagesEventError:
+		var d ErrorResponse
+		if err := json.Unmarshal(data, &d); err != nil {
+			return false, err
+		}
+		event.Error = &d
+		s.err = newError(s.resp, data, d.Error)
+	case MessagesEventPing:
+		var d MessagesEventPingData
+		if err := json.Unmarshal(data, &d); err != nil {
+			return false, err
+		}
+		event.Ping = &d
+	case MessagesEventMessageStart:
+		var d MessagesEventMessageStartData
+		if err := json.Unmarshal(data, &d); err != nil {
+			return false, err
+		}
+		event.MessageStart = &d
+		header := s.message.httpHeader
+		s.message = d.Message
+		s.message.httpHeader = header
+	case MessagesEventContentBlockStart:
+		var d MessagesEventContentBlockStartData
+		if err := json.Unmarshal(data, &d); err != nil {
+			return false, err
+		}
+		event.ContentBlockStart = &d
+		s.message.Content = slices.Insert(s.message.Content, d.Index, d.ContentBlock)
+		// inserting a block shifts the indexes of the following ones
+		clear(s.partialInputs)
+	case MessagesEventContentBlockDelta:
+		var d MessagesEventContentBlockDeltaData
+		if err := json.Unmarshal(data, &d); err != nil {
+			return false, err
+		}
+		event.ContentBlockDelta = &d
+		if len(s.message.Content)-1 < d.Index {
+			s.message.Content = slices.Insert(s.message.Content, d.Index, d.Delta)
+		} else if d.Delta.Type == MessagesContentTypeInputJsonDelta && d.Delta.PartialJson != nil {
+			s.appendPartialJSON(d.Index, *d.Delta.PartialJson)
+		} else {
+			s.message.Content[d.Index].MergeContentDelta(d.Delta)
+		}
+	case MessagesEventContentBlockStop:
+		var d MessagesEventContentBlockStopData
+		if err := json.Unmarshal(data, &d); err != nil {
+			return false, err
+		}
+		event.ContentBlockStop = &d
+		if len(s.message.Content) > d.Index {
+			stopContent := s.message.Content[d.Index]
+			if stopContent.Type == MessagesContentTypeToolUse && stopContent.PartialJson != nil {
+				toolUse := *stopContent.MessageContentToolUse
+				toolUse.Input = json.RawMessage(*stopContent.PartialJson)
+				stopContent.MessageContentToolUse = &toolUse
+				stopContent.PartialJson = nil
+				s.message.Content[d.Index] = stopContent
 			}
+			event.Content = stopContent
+		}
+	case MessagesEventMessageDelta:
+		var d MessagesEventMessageDeltaData
+		if err := json.Unmarshal(data, &d); err != nil {
+			return false, err
+		}
+		event.MessageDelta = &d
+		s.message.StopReason = d.Delta.StopReason
+		s.message.StopSequence = d.Delta.StopSequence
+		s.message.Usage.OutputTokens = d.Usage.OutputTokens
+	case MessagesEventMessageStop:
+		var d MessagesEventMessageStopData
+		if err := json.Unmarshal(data, &d); err != nil {
+			return false, err
+		}
+		event.MessageStop = &d
+	default:
+		return false, nil
+	}
+
+	s.event = event
+	return true, nil
+}
+
+// Event returns the event read by the last call to Next.
+func (s *MessagesStream) Event() MessagesStreamEvent {
+	return s.event
+}
+
+// Message returns a snapshot of the message accumulated from the events read so far.
+func (s *MessagesStream) Message() MessagesResponse {
+	message := s.message
+	message.Content = slices.Clone(s.message.Content)
+	return message
+}
+
+// PartialToolInput returns a best-effort decoding of the input of the tool_use block at index
+// streamed so far: unterminated strings hold their characters so far, and members whose value
+// is not known yet are left out. It returns false if the block has no partial input or it is invalid.
+// Each call only parses the partial JSON received since the previous one, and the returned
+// input shares the members terminated before with the following ones, so it must not be modified.
+func (s *MessagesStream) PartialToolInput(index int) (map[string]any, bool) {
+	if index < 0 || index >= len(s.message.Content) || s.message.Content[index].PartialJson == nil {
+		return nil, false
+	}
+	data := *s.message.Content[index].PartialJson
+	input := s.partialInput(index)
+	if input.length > len(data) {
+		input.parser, input.length = partialjson.Parser{}, 0
+	}
+	if input.length == 0 || input.length < len(data) {
+		err := input.parser.Write([]byte(data[input.length:]))
+		input.length = len(data)
+		input.value, input.ok = nil, false
+		if err == nil {
+			input.value, input.ok = input.parser.Value().(map[string]any)
 		}
-		emptyMessageCount++
-		if emptyMessageCount > c.config.EmptyMessagesLimit {
-			return response, ErrTooManyEmptyStreamMessages
+	}
+	return input.value, input.ok
+}
+
+// appendPartialJSON appends the partial JSON of a delta to the partial input of the block at index.
+func (s *MessagesStream) appendPartialJSON(index int, partialJSON string) {
+	content := &s.message.Content[index]
+	input := s.partialInput(index)
+	if input.json.Len() == 0 && content.PartialJson != nil {
+		input.json.WriteString(*content.PartialJson)
+	}
+	input.json.WriteString(partialJSON)
+	data := input.json.String()
+	content.PartialJson = &data
+}
+
+func (s *MessagesStream) partialInput(index int) *partialToolInput {
+	input := s.partialInputs[index]
+	if input == nil {
+		input = &partialToolInput{}
+		if s.partialInputs == nil {
+			s.partialInputs = make(map[int]*partialToolInput)
+		}
+		s.partialInputs[index] = input
+	}
+	return input
+}
+
+// Err returns the error that stopped Next, if any. An error event is returned as an *Error.
+func (s *MessagesStream) Err() error {
+	return s.err
+}
+
+// Close closes the underlying connection, Next returns false afterwards.
+func (s *MessagesStream) Close() error {
+	if s.closed || s.resp == nil {
+		return nil
+	}
+	s.closed = true
+	return s.resp.Body.Close()
+}
+
+// All returns an iterator over the remaining events, for use with range-over-func (iter.Seq2).
+// If the stream stops with an error, it is yielded last along with a zero event.
+func (s *MessagesStream) All() func(yield func(MessagesStreamEvent, error) bool) {
+	return func(yield func(MessagesStreamEvent, error) bool) {
+		for s.Next() {
+			if !yield(s.Event(), nil) {
+				return
+			}
+		}
+		if err := s.Err(); err != nil {
+			yield(MessagesStreamEvent{}, err)
+		}
+	}
+}
+
+func (c *Client) CreateMessagesStream(ctx context.Context, request MessagesStreamRequest) (response MessagesResponse, err error) {
+	stream, err := c.newMessagesStream(ctx, &request.MessagesRequest)
+	if err != nil {
+		return stream.Message(), err
+	}
+	defer stream.Close()
+
+	for stream.Next() {
+		event := stream.Event()
+		switch event.Type {
+		case MessagesEventError:
+			if request.OnError != nil {
+				request.OnError(*event.Error)
+			}
+		case MessagesEventPing:
+			if request.OnPing != nil {
+				request.OnPing(*event.Ping)
+			}
+		case MessagesEventMessageStart:
+			if request.OnMessageStart != nil {
+				request.OnMessageStart(*event.MessageStart)
+			}
+		case MessagesEventContentBlockStart:
+			if request.OnContentBlockStart != nil {
+				request.OnContentBlockStart(*event.ContentBlockStart)
+			}
+		case MessagesEventContentBlockDelta:
+			if request.OnContentBlockDelta != nil {
+				request.OnContentBlockDelta(*event.ContentBlockDelta)
+			}
+			if request.OnToolInputDelta != nil && event.ContentBlockDelta.Delta.Type == MessagesContentTypeInputJsonDelta {
+				if partial, ok := stream.PartialToolInput(event.ContentBlockDelta.Index); ok {
+					request.OnToolInputDelta(event.ContentBlockDelta.Index, partial)
+				}
+			}
+		case MessagesEventContentBlockStop:
+			if request.OnContentBlockStop != nil {
+				request.OnContentBlockStop(*event.ContentBlockStop, event.Content)
+			}
+		case MessagesEventMessageDelta:
+			if request.OnMessageDelta != nil {
+				request.OnMessageDelta(*event.MessageDelta)
+			}
+		case MessagesEventMessageStop:
+			if request.OnMessageStop != nil {
+				request.OnMessageStop(*event.MessageStop)
+			}
 		}
 	}
-	return
+	return stream.Message(), stream.Err()
 }
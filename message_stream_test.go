@@ -2,8 +2,11 @@ package anthropic_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -200,6 +203,382 @@ func TestMessagesStreamToolUse(t *testing.T) {
 	}
 }
 
+func TestNewMessagesStream(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", handlerMessagesStreamToolUse)
+
+	ts := server.AnthropicTestServer()
+	ts.Start()
+	defer ts.Close()
+
+	baseUrl := ts.URL + "/v1"
+	client := anthropic.NewClient(
+		test.GetTestToken(),
+		anthropic.WithBaseURL(baseUrl),
+	)
+	stream, err := client.NewMessagesStream(context.Background(), anthropic.MessagesRequest{
+		Model: anthropic.ModelClaude3Opus20240229,
+		Messages: []anthropic.Message{
+			anthropic.NewUserTextMessage("What is the weather like in San Francisco?"),
+		},
+		MaxTokens: 1000,
+		Tools: []anthropic.ToolDefinition{
+			{Name: "get_weather", InputSchema: jsonschema.Definition{Type: jsonschema.Object}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewMessagesStream error: %s", err)
+	}
+	defer stream.Close()
+
+	var (
+		events       []anthropic.MessagesEvent
+		stopped      anthropic.MessageContent
+		partialJsons []string
+	)
+	for stream.Next() {
+		event := stream.Event()
+		events = append(events, event.Type)
+		switch event.Type {
+		case anthropic.MessagesEventContentBlockDelta:
+			if p := stream.Message().Content[0].PartialJson; p != nil {
+				partialJsons = append(partialJsons, *p)
+			}
+		case anthropic.MessagesEventContentBlockStop:
+			stopped = event.Content
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream error: %s", err)
+	}
+
+	expectedEvents := []anthropic.MessagesEvent{
+		anthropic.MessagesEventMessageStart,
+		anthropic.MessagesEventContentBlockStart,
+		anthropic.MessagesEventContentBlockDelta,
+		anthropic.MessagesEventContentBlockDelta,
+		anthropic.MessagesEventContentBlockStop,
+		anthropic.MessagesEventMessageDelta,
+		anthropic.MessagesEventMessageStop,
+	}
+	if fmt.Sprint(events) != fmt.Sprint(expectedEvents) {
+		t.Fatalf("unexpected events: %v", events)
+	}
+	if len(partialJsons) != 2 || partialJsons[0] != `{"location":` {
+		t.Fatalf("unexpected partial json snapshots: %q", partialJsons)
+	}
+	if stopped.MessageContentToolUse == nil || string(stopped.Input) != `{"location":"San Francisco, CA"}` {
+		t.Fatalf("unexpected content block stop content: %+v", stopped)
+	}
+
+	message := stream.Message()
+	if message.ID != "123333" || message.StopReason != anthropic.MessagesStopReasonToolUse || message.Usage.OutputTokens != 9 {
+		t.Fatalf("unexpected message: %+v", message)
+	}
+	if message.Content[0].PartialJson != nil || string(message.Content[0].Input) != `{"location":"San Francisco, CA"}` {
+		t.Fatalf("unexpected content: %+v", message.Content[0])
+	}
+}
+
+func TestNewMessagesStreamAll(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", handlerMessagesStream)
+
+	ts := server.AnthropicTestServer()
+	ts.Start()
+	defer ts.Close()
+
+	baseUrl := ts.URL + "/v1"
+	client := anthropic.NewClient(
+		test.GetTestToken(),
+		anthropic.WithBaseURL(baseUrl),
+	)
+	request := anthropic.MessagesRequest{
+		Model: anthropic.ModelClaudeInstant1Dot2,
+		Messages: []anthropic.Message{
+			anthropic.NewUserTextMessage("What is your name?"),
+		},
+		MaxTokens: 1000,
+	}
+
+	// stop early after the first text delta
+	stream, err := client.NewMessagesStream(context.Background(), request)
+	if err != nil {
+		t.Fatalf("NewMessagesStream error: %s", err)
+	}
+	var received string
+	stream.All()(func(event anthropic.MessagesStreamEvent, err error) bool {
+		if err != nil {
+			t.Fatalf("stream error: %s", err)
+		}
+		if event.Type == anthropic.MessagesEventContentBlockDelta {
+			received += event.ContentBlockDelta.Delta.GetText()
+			return false
+		}
+		return true
+	})
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close error: %s", err)
+	}
+	if received != testMessagesStreamContent[0] || stream.Next() {
+		t.Fatalf("stream should have stopped after the first delta, got %q", received)
+	}
+
+	// an error event is yielded, followed by the error
+	request.SetTemperature(2)
+	stream, err = client.NewMessagesStream(context.Background(), request)
+	if err != nil {
+		t.Fatalf("NewMessagesStream error: %s", err)
+	}
+	defer stream.Close()
+
+	var (
+		events  []anthropic.MessagesEvent
+		lastErr error
+	)
+	stream.All()(func(event anthropic.MessagesStreamEvent, err error) bool {
+		events = append(events, event.Type)
+		lastErr = err
+		return true
+	})
+	if len(events) != 2 || events[0] != anthropic.MessagesEventError {
+		t.Fatalf("unexpected events: %v", events)
+	}
+	var e *anthropic.APIError
+	if !errors.As(lastErr, &e) || !e.IsOverloadedErr() {
+		t.Fatalf("expected overloaded error, got %v", lastErr)
+	}
+}
+
+func TestMessagesStreamCRLF(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		handlerMessagesStream(rec, r)
+		w.Header().Set("Content-Type", "text/event-stream")
+		body := strings.ReplaceAll(rec.Body.String(), "\n", "\r\n")
+		_, _ = w.Write([]byte(": keep-alive\r\n\r\n" + body))
+	})
+
+	ts := server.AnthropicTestServer()
+	ts.Start()
+	defer ts.Close()
+
+	baseUrl := ts.URL + "/v1"
+	client := anthropic.NewClient(
+		test.GetTestToken(),
+		anthropic.WithBaseURL(baseUrl),
+	)
+	resp, err := client.CreateMessagesStream(context.Background(), anthropic.MessagesStreamRequest{
+		MessagesRequest: anthropic.MessagesRequest{
+			Model: anthropic.ModelClaudeInstant1Dot2,
+			Messages: []anthropic.Message{
+				anthropic.NewUserTextMessage("What is your name?"),
+			},
+			MaxTokens: 1000,
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateMessagesStream error: %s", err)
+	}
+
+	expectedContent := strings.Join(testMessagesStreamContent, "")
+	if resp.GetFirstContentText() != expectedContent {
+		t.Fatalf("CreateMessagesStream content not match expected: %s, got: %s", expectedContent, resp.GetFirstContentText())
+	}
+}
+
+func TestMessagesStreamThinking(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", handlerMessagesStreamThinking)
+
+	ts := server.AnthropicTestServer()
+	ts.Start()
+	defer ts.Close()
+
+	baseUrl := ts.URL + "/v1"
+	client := anthropic.NewClient(
+		test.GetTestToken(),
+		anthropic.WithBaseURL(baseUrl),
+	)
+	var stopped []anthropic.MessageContent
+	resp, err := client.CreateMessagesStream(context.Background(), anthropic.MessagesStreamRequest{
+		MessagesRequest: anthropic.MessagesRequest{
+			Model: anthropic.ModelClaude37Sonnet20250219,
+			Messages: []anthropic.Message{
+				anthropic.NewUserTextMessage("What is 27 * 453?"),
+			},
+			MaxTokens: 16000,
+			Thinking:  anthropic.NewThinking(10000),
+		},
+		OnContentBlockStop: func(data anthropic.MessagesEventContentBlockStopData, content anthropic.MessageContent) {
+			stopped = append(stopped, content)
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateMessagesStream error: %s", err)
+	}
+
+	if len(resp.Content) != 2 || len(stopped) != 2 {
+		t.Fatalf("unexpected content: %+v", resp.Content)
+	}
+	thinking := resp.Content[0]
+	if thinking.Type != anthropic.MessagesContentTypeThinking ||
+		thinking.GetThinking() != "Let me solve this step by step:\n\n1. First break down 27 * 453" ||
+		thinking.GetSignature() != "EqQBCgIYAhIM1gbcDa9GJwZA2b3h" {
+		t.Fatalf("unexpected thinking block: %+v", thinking)
+	}
+	if stopped[0].GetSignature() != thinking.GetSignature() {
+		t.Fatalf("unexpected stopped thinking block: %+v", stopped[0])
+	}
+	if resp.Content[1].GetText() != "27 * 453 = 12,231" {
+		t.Fatalf("unexpected text block: %+v", resp.Content[1])
+	}
+}
+
+func TestMessagesStreamToolInputDelta(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", handlerMessagesStreamToolInputDelta)
+
+	ts := server.AnthropicTestServer()
+	ts.Start()
+	defer ts.Close()
+
+	baseUrl := ts.URL + "/v1"
+	client := anthropic.NewClient(
+		test.GetTestToken(),
+		anthropic.WithBaseURL(baseUrl),
+	)
+
+	var partials []map[string]any
+	_, err := client.CreateMessagesStream(context.Background(), anthropic.MessagesStreamRequest{
+		MessagesRequest: anthropic.MessagesRequest{
+			Model: anthropic.ModelClaude3Opus20240229,
+			Messages: []anthropic.Message{
+				anthropic.NewUserTextMessage("What is the weather like in San Francisco?"),
+			},
+			MaxTokens: 1000,
+		},
+		OnToolInputDelta: func(index int, partial map[string]any) {
+			if index != 1 {
+				t.Errorf("unexpected index: %d", index)
+			}
+			partials = append(partials, partial)
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateMessagesStream error: %s", err)
+	}
+
+	want := []map[string]any{
+		{"location": "San"},
+		{"location": "San Francisco, CA", "unit": "cel"},
+		{"location": "San Francisco, CA", "unit": "celsius"},
+	}
+	if !reflect.DeepEqual(partials, want) {
+		t.Fatalf("unexpected partial inputs: %v", partials)
+	}
+}
+
+// BenchmarkMessagesStreamToolInputDelta streams a 200KB tool input in 10001 deltas.
+func BenchmarkMessagesStreamToolInputDelta(b *testing.B) {
+	delta := func(partialJSON string) string {
+		return "event: content_block_delta\n" +
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"` + partialJSON + `"}}`
+	}
+	events := []string{
+		"event: message_start\n" + `data: {"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","content":[],"model":"claude-3-opus-20240229","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":40,"output_tokens":1}}}`,
+		"event: content_block_start\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_01","name":"write_file","input":{}}}`,
+		delta(`{\"content\": \"`),
+	}
+	for i := 0; i < 9999; i++ {
+		events = append(events, delta(strings.Repeat("a", 20)))
+	}
+	events = append(events,
+		delta(`\"}`),
+		"event: content_block_stop\n"+`data: {"type":"content_block_stop","index":0}`,
+		"event: message_stop\n"+`data: {"type":"message_stop"}`,
+	)
+	body := []byte(strings.Join(events, "\n\n") + "\n\n")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write(body)
+	}))
+	defer ts.Close()
+	client := anthropic.NewClient(test.GetTestToken(), anthropic.WithBaseURL(ts.URL))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var deltas int
+		_, err := client.CreateMessagesStream(context.Background(), anthropic.MessagesStreamRequest{
+			MessagesRequest: anthropic.MessagesRequest{
+				Model:     anthropic.ModelClaude3Opus20240229,
+				Messages:  []anthropic.Message{anthropic.NewUserTextMessage("Write a file")},
+				MaxTokens: 1000,
+			},
+			OnToolInputDelta: func(index int, partial map[string]any) {
+				deltas++
+			},
+		})
+		if err != nil {
+			b.Fatalf("CreateMessagesStream error: %s", err)
+		}
+		if deltas != 10001 {
+			b.Fatalf("expected 10001 partial inputs, got %d", deltas)
+		}
+	}
+}
+
+func TestMessagesStreamCitations(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			"event: message_start\n" + `data: {"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","content":[],"model":"claude-3-5-sonnet-20240620","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":40,"output_tokens":1}}}`,
+			"event: content_block_start\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"the grass is green"}}`,
+			"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"citations_delta","citation":{"type":"char_location","cited_text":"The grass is green.","document_index":0,"document_title":"Example Document","start_char_index":0,"end_char_index":20}}}`,
+			"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"citations_delta","citation":{"type":"page_location","cited_text":"Green grass","document_index":1,"document_title":null,"start_page_number":3,"end_page_number":4}}}`,
+			"event: content_block_stop\n" + `data: {"type":"content_block_stop","index":0}`,
+			"event: message_stop\n" + `data: {"type":"message_stop"}`,
+		}
+		_, _ = w.Write([]byte(strings.Join(events, "\n\n") + "\n\n"))
+	}))
+	defer ts.Close()
+
+	client := anthropic.NewClient(test.GetTestToken(), anthropic.WithBaseURL(ts.URL))
+	stream, err := client.NewMessagesStream(context.Background(), anthropic.MessagesRequest{
+		Model:     anthropic.ModelClaude35Sonnet20240620,
+		Messages:  []anthropic.Message{anthropic.NewUserTextMessage("What color is the grass?")},
+		MaxTokens: 1000,
+	})
+	if err != nil {
+		t.Fatalf("NewMessagesStream error: %s", err)
+	}
+	defer stream.Close()
+
+	var snapshots []anthropic.MessagesResponse
+	for stream.Next() {
+		if delta := stream.Event().ContentBlockDelta; delta != nil && delta.Delta.Type == anthropic.MessagesContentTypeCitationsDelta {
+			snapshots = append(snapshots, stream.Message())
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream error: %s", err)
+	}
+
+	content := stream.Message().Content[0]
+	if content.GetText() != "the grass is green" || content.Citations == nil || len(content.Citations.Items) != 2 {
+		t.Fatalf("unexpected content: %+v", content)
+	}
+	if c := content.Citations.Items[1]; c.Type != anthropic.CitationTypePageLocation || c.EndPageNumber != 4 || c.DocumentTitle != nil {
+		t.Fatalf("unexpected citation: %+v", c)
+	}
+	if len(snapshots) != 2 || len(snapshots[0].Content[0].Citations.Items) != 1 {
+		t.Fatalf("snapshots should not be modified by later deltas")
+	}
+}
+
 func handlerMessagesStream(w http.ResponseWriter, r *http.Request) {
 	request, err := getMessagesRequest(r)
 	if err != nil {
@@ -300,3 +679,48 @@ func handlerMessagesStreamToolUse(w http.ResponseWriter, r *http.Request) {
 
 	_, _ = w.Write(dataBytes)
 }
+
+func handlerMessagesStreamThinking(w http.ResponseWriter, r *http.Request) {
+	request, err := getMessagesRequest(r)
+	if err != nil || request.Thinking == nil {
+		http.Error(w, "request error", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+
+	events := []string{
+		"event: message_start\n" + `data: {"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","content":[],"model":"claude-3-7-sonnet-20250219","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":40,"output_tokens":1}}}`,
+		"event: content_block_start\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me solve this step by step:\n\n"}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"1. First break down 27 * 453"}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"EqQBCgIYAhIM1gbcDa9GJwZA2b3h"}}`,
+		"event: content_block_stop\n" + `data: {"type":"content_block_stop","index":0}`,
+		"event: content_block_start\n" + `data: {"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"27 * 453 = 12,231"}}`,
+		"event: content_block_stop\n" + `data: {"type":"content_block_stop","index":1}`,
+		"event: message_delta\n" + `data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":55}}`,
+		"event: message_stop\n" + `data: {"type":"message_stop"}`,
+	}
+	_, _ = w.Write([]byte(strings.Join(events, "\n\n") + "\n\n"))
+}
+
+func handlerMessagesStreamToolInputDelta(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+
+	events := []string{
+		"event: message_start\n" + `data: {"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","content":[],"model":"claude-3-opus-20240229","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":40,"output_tokens":1}}}`,
+		"event: content_block_start\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me check."}}`,
+		"event: content_block_stop\n" + `data: {"type":"content_block_stop","index":0}`,
+		"event: content_block_start\n" + `data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{}}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":""}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"location\": \"San"}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":" Francisco, CA\", \"unit\": \"cel"}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"sius\"}"}}`,
+		"event: content_block_stop\n" + `data: {"type":"content_block_stop","index":1}`,
+		"event: message_delta\n" + `data: {"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":55}}`,
+		"event: message_stop\n" + `data: {"type":"message_stop"}`,
+	}
+	_, _ = w.Write([]byte(strings.Join(events, "\n\n") + "\n\n"))
+}
@@ -5,6 +5,7 @@ import (
 	"embed"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
@@ -272,6 +273,287 @@ func TestMessagesRateLimitHeaders(t *testing.T) {
 	}
 }
 
+func TestParseRateLimitHeaders(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range map[string]string{
+			"anthropic-ratelimit-requests-limit":          "100",
+			"anthropic-ratelimit-requests-remaining":      "99",
+			"anthropic-ratelimit-requests-reset":          "2024-06-04T07:13:19Z",
+			"anthropic-ratelimit-tokens-limit":            "10000",
+			"anthropic-ratelimit-tokens-remaining":        "9900",
+			"anthropic-ratelimit-tokens-reset":            "2024-06-04T07:13:19Z",
+			"anthropic-ratelimit-input-tokens-limit":      "8000",
+			"anthropic-ratelimit-input-tokens-remaining":  "7900",
+			"anthropic-ratelimit-input-tokens-reset":      "2024-06-04T07:13:20Z",
+			"anthropic-ratelimit-output-tokens-limit":     "2000",
+			"anthropic-ratelimit-output-tokens-remaining": "2000",
+			"anthropic-ratelimit-output-tokens-reset":     "2024-06-04T07:13:21Z",
+		} {
+			w.Header().Set(k, v)
+		}
+		w.Header().Set("retry-after", "100")
+		_, _ = w.Write([]byte(`{"type":"message","content":[]}`))
+	})
+
+	client := server.NewClient(t)
+	resp, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.NoError(t, err, "CreateMessages error")
+
+	rateLimitHeaders, err := resp.ParseRateLimitHeaders()
+	checks.NoError(t, err, "ParseRateLimitHeaders error")
+
+	want := anthropic.RateLimitHeaders{
+		RequestsLimit:         100,
+		RequestsRemaining:     99,
+		RequestsReset:         time.Date(2024, 6, 4, 7, 13, 19, 0, time.UTC),
+		TokensLimit:           10000,
+		TokensRemaining:       9900,
+		TokensReset:           time.Date(2024, 6, 4, 7, 13, 19, 0, time.UTC),
+		InputTokensLimit:      8000,
+		InputTokensRemaining:  7900,
+		InputTokensReset:      time.Date(2024, 6, 4, 7, 13, 20, 0, time.UTC),
+		OutputTokensLimit:     2000,
+		OutputTokensRemaining: 2000,
+		OutputTokensReset:     time.Date(2024, 6, 4, 7, 13, 21, 0, time.UTC),
+		RetryAfter:            100 * time.Second,
+	}
+	if rateLimitHeaders != want {
+		t.Fatalf("rate limit headers mismatch. got %+v, want %+v", rateLimitHeaders, want)
+	}
+	if resp.GetRateLimitHeaders() != want {
+		t.Fatalf("GetRateLimitHeaders mismatch. got %+v, want %+v", resp.GetRateLimitHeaders(), want)
+	}
+}
+
+func TestRateLimitHeadersErrors(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("anthropic-ratelimit-requests-limit", "100")
+		w.Header().Set("anthropic-ratelimit-input-tokens-limit", "many")
+		w.Header().Set("anthropic-ratelimit-output-tokens-reset", "soon")
+		w.Header().Set("retry-after", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"Rate limited"}}`))
+	})
+
+	client := server.NewClient(t)
+	_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	var e *anthropic.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *anthropic.Error, got %v", err)
+	}
+
+	rateLimitHeaders, err := e.ParseRateLimitHeaders()
+	checks.HasError(t, err, "malformed headers should be reported")
+	for _, header := range []string{"anthropic-ratelimit-input-tokens-limit", "anthropic-ratelimit-output-tokens-reset"} {
+		if !strings.Contains(err.Error(), header) {
+			t.Errorf("the error should report the %s header: %v", header, err)
+		}
+	}
+	if rateLimitHeaders.RequestsLimit != 100 || rateLimitHeaders.InputTokensLimit != 0 {
+		t.Errorf("unexpected rate limit headers: %+v", rateLimitHeaders)
+	}
+	if d := rateLimitHeaders.RetryAfter; d < 59*time.Minute || d > time.Hour {
+		t.Errorf("unexpected retry after: %s", d)
+	}
+	if e.RateLimitHeaders.RequestsLimit != 100 || e.RateLimitHeaders.RetryAfter == 0 {
+		t.Errorf("unexpected RateLimitHeaders: %+v", e.RateLimitHeaders)
+	}
+}
+
+func TestMessagesPromptCaching(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", handleMessagesPromptCachingEndpoint)
+
+	ts := server.AnthropicTestServer()
+	ts.Start()
+	defer ts.Close()
+
+	baseUrl := ts.URL + "/v1"
+	client := anthropic.NewClient(
+		test.GetTestToken(),
+		anthropic.WithBaseURL(baseUrl),
+	)
+
+	cacheControl := &anthropic.MessageCacheControl{Type: anthropic.CacheControlTypeEphemeral}
+	systemPart := anthropic.NewMessageSystemPart(strings.Repeat("You are an AI assistant analyzing literary works. ", 100))
+	systemPart.CacheControl = cacheControl
+
+	resp, err := client.CreateMessages(context.Background(), anthropic.MessagesRequest{
+		Model: anthropic.ModelClaude35Sonnet20240620,
+		MultiSystem: []anthropic.MessageSystemPart{
+			anthropic.NewMessageSystemPart("You are a helpful assistant."),
+			systemPart,
+		},
+		Messages: []anthropic.Message{
+			anthropic.NewUserTextMessage("Analyze the major themes in Pride and Prejudice."),
+		},
+		MaxTokens: 1000,
+	})
+	if err != nil {
+		t.Fatalf("CreateMessages error: %v", err)
+	}
+	if resp.Usage.CacheCreationInputTokens != 1200 || resp.Usage.CacheReadInputTokens != 0 {
+		t.Fatalf("unexpected usage: %+v", resp.Usage)
+	}
+
+	// a plain string system prompt is still sent as a string, without the beta header
+	resp, err = client.CreateMessages(context.Background(), anthropic.MessagesRequest{
+		Model:  anthropic.ModelClaude35Sonnet20240620,
+		System: "You are a helpful assistant.",
+		Messages: []anthropic.Message{
+			anthropic.NewUserTextMessage("What is your name?"),
+		},
+		MaxTokens: 1000,
+	})
+	if err != nil {
+		t.Fatalf("CreateMessages error: %v", err)
+	}
+	if resp.Usage.CacheCreationInputTokens != 0 {
+		t.Fatalf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestMessagesRequestSystemJSON(t *testing.T) {
+	request := anthropic.MessagesRequest{
+		Model:       anthropic.ModelClaude35Sonnet20240620,
+		System:      "ignored",
+		MultiSystem: []anthropic.MessageSystemPart{anthropic.NewMessageSystemPart("You are a helpful assistant.")},
+		MaxTokens:   1000,
+	}
+
+	bs, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded anthropic.MessagesRequest
+	if err := json.Unmarshal(bs, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.System != "" || len(decoded.MultiSystem) != 1 || decoded.MultiSystem[0].Text != "You are a helpful assistant." {
+		t.Fatalf("unexpected round trip of %s: %+v", bs, decoded)
+	}
+	if decoded.Model != request.Model || decoded.MaxTokens != request.MaxTokens {
+		t.Fatalf("unexpected round trip of %s: %+v", bs, decoded)
+	}
+}
+
+func TestMessagesThinking(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", handleMessagesThinkingEndpoint)
+
+	ts := server.AnthropicTestServer()
+	ts.Start()
+	defer ts.Close()
+
+	baseUrl := ts.URL + "/v1"
+	client := anthropic.NewClient(
+		test.GetTestToken(),
+		anthropic.WithBaseURL(baseUrl),
+	)
+
+	request := anthropic.MessagesRequest{
+		Model: anthropic.ModelClaude37Sonnet20250219,
+		Messages: []anthropic.Message{
+			anthropic.NewUserTextMessage("What is the weather like in San Francisco?"),
+		},
+		MaxTokens: 16000,
+		Thinking:  anthropic.NewThinking(10000),
+		Tools: []anthropic.ToolDefinition{
+			{Name: "get_weather", InputSchema: jsonschema.Definition{Type: jsonschema.Object}},
+		},
+	}
+
+	resp, err := client.CreateMessages(context.Background(), request)
+	if err != nil {
+		t.Fatalf("CreateMessages error: %v", err)
+	}
+	if len(resp.Content) != 3 {
+		t.Fatalf("unexpected content: %+v", resp.Content)
+	}
+	if resp.Content[0].Type != anthropic.MessagesContentTypeThinking || resp.Content[0].GetThinking() == "" ||
+		resp.Content[0].GetSignature() == "" {
+		t.Fatalf("unexpected thinking block: %+v", resp.Content[0])
+	}
+	if resp.Content[1].Type != anthropic.MessagesContentTypeRedactedThinking || resp.Content[1].Data == nil {
+		t.Fatalf("unexpected redacted thinking block: %+v", resp.Content[1])
+	}
+
+	request.Messages = append(request.Messages,
+		resp.ToMessage(),
+		anthropic.NewToolResultsMessage(resp.Content[2].ID, "65 degrees", false),
+	)
+	resp, err = client.CreateMessages(context.Background(), request)
+	if err != nil {
+		t.Fatalf("CreateMessages error: %v", err)
+	}
+	if !strings.Contains(resp.GetFirstContentText(), "65 degrees") {
+		t.Fatalf("unexpected response: %+v", resp.Content)
+	}
+}
+
+func TestMessagesDocumentCitations(t *testing.T) {
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", handleMessagesDocumentEndpoint)
+
+	ts := server.AnthropicTestServer()
+	ts.Start()
+	defer ts.Close()
+
+	client := anthropic.NewClient(test.GetTestToken(), anthropic.WithBaseURL(ts.URL+"/v1"))
+
+	pdf := anthropic.NewDocumentMessageContent(anthropic.NewPDFSource("JVBERi0xLjQK"))
+	text := anthropic.NewDocumentMessageContent(anthropic.NewPlainTextSource("The grass is green. The sky is blue."))
+	title := "My Document"
+	text.Title = &title
+	text.Citations = &anthropic.MessageContentCitations{Enabled: true}
+	custom := anthropic.NewDocumentMessageContent(anthropic.NewContentSource(
+		anthropic.NewTextMessageContent("First chunk"),
+		anthropic.NewTextMessageContent("Second chunk"),
+	))
+	url := anthropic.NewDocumentMessageContent(anthropic.NewURLSource("https://example.com/doc.pdf"))
+
+	request := anthropic.MessagesRequest{
+		Model:     anthropic.ModelClaude35Sonnet20240620,
+		MaxTokens: 1000,
+		Messages: []anthropic.Message{
+			{
+				Role: anthropic.RoleUser,
+				Content: []anthropic.MessageContent{
+					pdf, text, custom, url,
+					anthropic.NewTextMessageContent("What color is the grass and sky?"),
+				},
+			},
+		},
+	}
+	resp, err := client.CreateMessages(context.Background(), request)
+	if err != nil {
+		t.Fatalf("CreateMessages error: %v", err)
+	}
+
+	citations := resp.Content[1].Citations
+	if citations == nil || len(citations.Items) != 3 {
+		t.Fatalf("unexpected citations: %+v", resp.Content[1])
+	}
+	if c := citations.Items[0]; c.Type != anthropic.CitationTypeCharLocation || c.EndCharIndex != 20 || *c.DocumentTitle != "My Document" {
+		t.Fatalf("unexpected char location: %+v", c)
+	}
+	if c := citations.Items[1]; c.Type != anthropic.CitationTypePageLocation || c.StartPageNumber != 1 || c.EndPageNumber != 2 {
+		t.Fatalf("unexpected page location: %+v", c)
+	}
+	if c := citations.Items[2]; c.Type != anthropic.CitationTypeContentBlockLocation || c.DocumentIndex != 2 || c.EndBlockIndex != 1 {
+		t.Fatalf("unexpected content block location: %+v", c)
+	}
+
+	request.Messages = append(request.Messages, resp.ToMessage(), anthropic.NewUserTextMessage("Thanks"))
+	_, err = client.CreateMessages(context.Background(), request)
+	if err != nil {
+		t.Fatalf("CreateMessages error: %v", err)
+	}
+}
+
 func handleMessagesEndpoint(w http.ResponseWriter, r *http.Request) {
 	var err error
 	var resBytes []byte
@@ -351,3 +633,143 @@ func getMessagesRequest(r *http.Request) (req anthropic.MessagesRequest, err err
 	}
 	return
 }
+
+func handleMessagesPromptCachingEndpoint(w http.ResponseWriter, r *http.Request) {
+	messagesReq, err := getMessagesRequest(r)
+	if err != nil {
+		http.Error(w, "could not read request", http.StatusInternalServerError)
+		return
+	}
+
+	hasBeta := strings.Contains(r.Header.Get("anthropic-beta"), anthropic.BetaPromptCaching20240731)
+	usage := anthropic.MessagesUsage{InputTokens: 10, OutputTokens: 10}
+	switch {
+	case len(messagesReq.MultiSystem) > 0:
+		if !hasBeta || messagesReq.MultiSystem[1].CacheControl == nil {
+			http.Error(w, "cache_control not sent", http.StatusBadRequest)
+			return
+		}
+		usage.CacheCreationInputTokens = 1200
+	case messagesReq.System != "":
+		if hasBeta {
+			http.Error(w, "unexpected beta header", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "system prompt not sent", http.StatusBadRequest)
+		return
+	}
+
+	resBytes, _ := json.Marshal(anthropic.MessagesResponse{
+		Type:       "message",
+		ID:         strconv.Itoa(int(time.Now().Unix())),
+		Role:       anthropic.RoleAssistant,
+		Content:    []anthropic.MessageContent{anthropic.NewTextMessageContent("hello")},
+		StopReason: anthropic.MessagesStopReasonEndTurn,
+		Model:      messagesReq.Model,
+		Usage:      usage,
+	})
+	_, _ = w.Write(resBytes)
+}
+
+func handleMessagesThinkingEndpoint(w http.ResponseWriter, r *http.Request) {
+	messagesReq, err := getMessagesRequest(r)
+	if err != nil {
+		http.Error(w, "could not read request", http.StatusInternalServerError)
+		return
+	}
+	if messagesReq.Thinking == nil || messagesReq.Thinking.Type != anthropic.ThinkingTypeEnabled || messagesReq.Thinking.BudgetTokens != 10000 {
+		http.Error(w, "thinking not enabled", http.StatusBadRequest)
+		return
+	}
+
+	res := anthropic.MessagesResponse{
+		Type:       "message",
+		ID:         strconv.Itoa(int(time.Now().Unix())),
+		Role:       anthropic.RoleAssistant,
+		StopReason: anthropic.MessagesStopReasonToolUse,
+		Model:      messagesReq.Model,
+		Content: []anthropic.MessageContent{
+			anthropic.NewThinkingMessageContent("The user wants the weather, I should call get_weather.", "EuYBCkQYAiJA"),
+			anthropic.NewRedactedThinkingMessageContent("EmwKAhgBEgy3va3pzix"),
+			anthropic.NewToolUseMessageContent("toolu_01", "get_weather", json.RawMessage(`{"location":"San Francisco, CA"}`)),
+		},
+	}
+
+	if len(messagesReq.Messages) > 1 {
+		// the thinking blocks of the previous turn must be passed back unmodified
+		previous := messagesReq.Messages[1].Content
+		if len(previous) != 3 || previous[0].GetSignature() != "EuYBCkQYAiJA" || previous[1].Data == nil ||
+			*previous[1].Data != "EmwKAhgBEgy3va3pzix" {
+			http.Error(w, "thinking blocks not passed back", http.StatusBadRequest)
+			return
+		}
+		res.StopReason = anthropic.MessagesStopReasonEndTurn
+		res.Content = []anthropic.MessageContent{
+			anthropic.NewTextMessageContent("The current weather in San Francisco is 65 degrees."),
+		}
+	}
+
+	resBytes, _ := json.Marshal(res)
+	_, _ = w.Write(resBytes)
+}
+
+func handleMessagesDocumentEndpoint(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Messages []struct {
+			Role    string           `json:"role"`
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "could not read request", http.StatusBadRequest)
+		return
+	}
+
+	content := req.Messages[0].Content
+	sources := []string{
+		`{"data":"JVBERi0xLjQK","media_type":"application/pdf","type":"base64"}`,
+		`{"data":"The grass is green. The sky is blue.","media_type":"text/plain","type":"text"}`,
+		`{"content":[{"text":"First chunk","type":"text"},{"text":"Second chunk","type":"text"}],"type":"content"}`,
+		`{"type":"url","url":"https://example.com/doc.pdf"}`,
+	}
+	for i, source := range sources {
+		bs, _ := json.Marshal(content[i]["source"])
+		if content[i]["type"] != "document" || string(bs) != source {
+			http.Error(w, fmt.Sprintf("unexpected document %d: %s", i, bs), http.StatusBadRequest)
+			return
+		}
+	}
+	if citations, _ := json.Marshal(content[1]["citations"]); content[1]["title"] != "My Document" || string(citations) != `{"enabled":true}` {
+		http.Error(w, "citations not enabled", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Messages) > 1 {
+		citations, _ := json.Marshal(req.Messages[1].Content[1]["citations"])
+		want := `[{"cited_text":"The grass is green.","document_index":1,"document_title":"My Document","end_char_index":20,"start_char_index":0,"type":"char_location"},` +
+			`{"cited_text":"Page one","document_index":0,"document_title":null,"end_page_number":2,"start_page_number":1,"type":"page_location"},` +
+			`{"cited_text":"Second chunk","document_index":2,"document_title":null,"end_block_index":1,"start_block_index":1,"type":"content_block_location"}]`
+		if string(citations) != want {
+			http.Error(w, fmt.Sprintf("unexpected citations: %s", citations), http.StatusBadRequest)
+			return
+		}
+	}
+
+	_, _ = w.Write([]byte(`{
+		"id": "msg_01",
+		"type": "message",
+		"role": "assistant",
+		"model": "claude-3-5-sonnet-20240620",
+		"content": [
+			{"type": "text", "text": "According to the documents, "},
+			{"type": "text", "text": "the grass is green", "citations": [
+				{"type": "char_location", "cited_text": "The grass is green.", "document_index": 1, "document_title": "My Document", "start_char_index": 0, "end_char_index": 20},
+				{"type": "page_location", "cited_text": "Page one", "document_index": 0, "document_title": null, "start_page_number": 1, "end_page_number": 2},
+				{"type": "content_block_location", "cited_text": "Second chunk", "document_index": 2, "document_title": null, "start_block_index": 1, "end_block_index": 1}
+			]}
+		],
+		"stop_reason": "end_turn",
+		"usage": {"input_tokens": 100, "output_tokens": 20}
+	}`))
+}
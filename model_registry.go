@@ -0,0 +1,277 @@
+package anthropic
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrMaxTokensExceeded = errors.New("max_tokens exceeds the maximum output tokens of the model")
+
+// ModelEntry describes a model and its IDs on the backends.
+type ModelEntry struct {
+	// ID is the canonical ID of the model on the Anthropic API.
+	ID string
+	// Aliases are other names the model can be requested with, e.g. "claude-3-opus-latest".
+	Aliases []string
+	// VertexID is the ID of the model on Vertex AI, empty if it is the same or the model is not available.
+	VertexID string
+	// BedrockID is the ID of the model on Amazon Bedrock, empty if it is the same or the model is not available.
+	BedrockID string
+	// BedrockInferenceProfile is set if the model can only be invoked on demand through a cross-region
+	// inference profile: the BedrockID is then prefixed with the geography of the region, e.g. "us.".
+	BedrockInferenceProfile bool
+
+	// ContextWindow is the maximum number of input and output tokens, zero if unknown.
+	ContextWindow int
+	// MaxOutputTokens is the maximum value of MaxTokens, zero if unknown.
+	MaxOutputTokens int
+
+	SupportsVision   bool
+	SupportsTools    bool
+	SupportsThinking bool
+
+	// DeprecationDate is the date the model was deprecated, zero if it is not or it is unknown.
+	// The default models leave it zero, see https://docs.anthropic.com/en/docs/resources/model-deprecations
+	DeprecationDate time.Time
+}
+
+// Deprecated reports whether the model is deprecated at time t.
+func (e ModelEntry) Deprecated(t time.Time) bool {
+	return !e.DeprecationDate.IsZero() && !t.Before(e.DeprecationDate)
+}
+
+// ModelRegistry holds the known models, looked up by ID or alias. It is safe for concurrent use,
+// so models can be registered while clients use the registry.
+type ModelRegistry struct {
+	mu      sync.RWMutex
+	models  map[string]ModelEntry
+	aliases map[string]string
+}
+
+// NewModelRegistry returns a registry with the entries, see Register.
+func NewModelRegistry(entries ...ModelEntry) (*ModelRegistry, error) {
+	r := &ModelRegistry{
+		models:  make(map[string]ModelEntry),
+		aliases: make(map[string]string),
+	}
+	if err := r.Register(entries...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Register adds the entries to the registry, replacing the entries with the same ID.
+// An alias can not be the ID or alias of another model.
+func (r *ModelRegistry) Register(entries ...ModelEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, entry := range entries {
+		if entry.ID == "" {
+			return errors.New("model ID is empty")
+		}
+		if id, ok := r.aliases[entry.ID]; ok && id != entry.ID {
+			return fmt.Errorf("model ID %q is an alias of %q", entry.ID, id)
+		}
+		for _, alias := range entry.Aliases {
+			if _, ok := r.models[alias]; ok && alias != entry.ID {
+				return fmt.Errorf("model alias %q is the ID of another model", alias)
+			}
+			if id, ok := r.aliases[alias]; ok && id != entry.ID {
+				return fmt.Errorf("model alias %q is an alias of %q", alias, id)
+			}
+		}
+
+		if old, ok := r.models[entry.ID]; ok {
+			for _, alias := range old.Aliases {
+				delete(r.aliases, alias)
+			}
+		}
+		entry.Aliases = slices.Clone(entry.Aliases)
+		r.models[entry.ID] = entry
+		for _, alias := range entry.Aliases {
+			r.aliases[alias] = entry.ID
+		}
+	}
+	return nil
+}
+
+// Lookup returns the entry of the model with the ID or alias.
+func (r *ModelRegistry) Lookup(model string) (ModelEntry, bool) {
+	if r == nil {
+		return ModelEntry{}, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if id, ok := r.aliases[model]; ok {
+		model = id
+	}
+	entry, ok := r.models[model]
+	if !ok {
+		return ModelEntry{}, false
+	}
+	entry.Aliases = slices.Clone(entry.Aliases)
+	return entry, true
+}
+
+// Models returns the entries of the registry, sorted by ID.
+func (r *ModelRegistry) Models() []ModelEntry {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]ModelEntry, 0, len(r.models))
+	for _, entry := range r.models {
+		entry.Aliases = slices.Clone(entry.Aliases)
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b ModelEntry) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return entries
+}
+
+// vertexID returns the Vertex AI ID of the model, the model itself if it is unknown.
+func (r *ModelRegistry) vertexID(model string) string {
+	if entry, ok := r.Lookup(model); ok && entry.VertexID != "" {
+		return entry.VertexID
+	}
+	return model
+}
+
+// bedrockID returns the Bedrock ID of the model in the region, the model itself if it is unknown.
+func (r *ModelRegistry) bedrockID(model, region string) string {
+	entry, ok := r.Lookup(model)
+	if !ok || entry.BedrockID == "" {
+		return model
+	}
+	if geography := bedrockGeography(region); entry.BedrockInferenceProfile && geography != "" {
+		return geography + "." + entry.BedrockID
+	}
+	return entry.BedrockID
+}
+
+// bedrockGeography returns the geography prefixing the IDs of the cross-region inference profiles
+// of the region, empty if it is unknown.
+func bedrockGeography(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return "us-gov"
+	case strings.HasPrefix(region, "us-"):
+		return "us"
+	case strings.HasPrefix(region, "eu-"):
+		return "eu"
+	case strings.HasPrefix(region, "ap-"):
+		return "apac"
+	default:
+		return ""
+	}
+}
+
+// checkMaxTokens returns an error if MaxTokens exceeds the maximum output tokens of a known model.
+// The check is skipped if the beta versions sent with the request raise the maximum output tokens.
+func (r *ModelRegistry) checkMaxTokens(request *MessagesRequest, betaVersions []string) error {
+	if slices.Contains(betaVersions, BetaOutput128k20250219) {
+		return nil
+	}
+
+	entry, ok := r.Lookup(request.Model)
+	if ok && entry.MaxOutputTokens > 0 && request.MaxTokens > entry.MaxOutputTokens {
+		return fmt.Errorf("%w: %d > %d for %s", ErrMaxTokensExceeded, request.MaxTokens, entry.MaxOutputTokens, request.Model)
+	}
+	return nil
+}
+
+// defaultModels are the models of the model constants.
+var defaultModels = []ModelEntry{
+	{
+		ID:              ModelClaudeInstant1Dot2,
+		BedrockID:       "anthropic.claude-instant-v1",
+		ContextWindow:   100000,
+		MaxOutputTokens: 4096,
+	},
+	{
+		ID:              ModelClaude2Dot0,
+		BedrockID:       "anthropic.claude-v2",
+		ContextWindow:   100000,
+		MaxOutputTokens: 4096,
+	},
+	{
+		ID:              ModelClaude2Dot1,
+		BedrockID:       "anthropic.claude-v2:1",
+		ContextWindow:   200000,
+		MaxOutputTokens: 4096,
+	},
+	{
+		ID:              ModelClaude3Opus20240229,
+		Aliases:         []string{"claude-3-opus-latest"},
+		VertexID:        "claude-3-opus@20240229",
+		BedrockID:       "anthropic.claude-3-opus-20240229-v1:0",
+		ContextWindow:   200000,
+		MaxOutputTokens: 4096,
+		SupportsVision:  true,
+		SupportsTools:   true,
+	},
+	{
+		ID:              ModelClaude3Sonnet20240229,
+		VertexID:        "claude-3-sonnet@20240229",
+		BedrockID:       "anthropic.claude-3-sonnet-20240229-v1:0",
+		ContextWindow:   200000,
+		MaxOutputTokens: 4096,
+		SupportsVision:  true,
+		SupportsTools:   true,
+	},
+	{
+		ID:              ModelClaude3Haiku20240307,
+		VertexID:        "claude-3-haiku@20240307",
+		BedrockID:       "anthropic.claude-3-haiku-20240307-v1:0",
+		ContextWindow:   200000,
+		MaxOutputTokens: 4096,
+		SupportsVision:  true,
+		SupportsTools:   true,
+	},
+	{
+		ID:              ModelClaude35Sonnet20240620,
+		VertexID:        "claude-3-5-sonnet@20240620",
+		BedrockID:       "anthropic.claude-3-5-sonnet-20240620-v1:0",
+		ContextWindow:   200000,
+		MaxOutputTokens: 8192,
+		SupportsVision:  true,
+		SupportsTools:   true,
+	},
+	{
+		ID:                      ModelClaude37Sonnet20250219,
+		Aliases:                 []string{"claude-3-7-sonnet-latest"},
+		VertexID:                "claude-3-7-sonnet@20250219",
+		BedrockID:               "anthropic.claude-3-7-sonnet-20250219-v1:0",
+		BedrockInferenceProfile: true,
+		ContextWindow:           200000,
+		MaxOutputTokens:         64000,
+		SupportsVision:          true,
+		SupportsTools:           true,
+		SupportsThinking:        true,
+	},
+}
+
+var defaultModelRegistry = func() *ModelRegistry {
+	r, err := NewModelRegistry(defaultModels...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}()
+
+// DefaultModelRegistry returns the registry the clients use by default, holding the models of this package.
+// Models registered to it are known to all the clients using it.
+func DefaultModelRegistry() *ModelRegistry {
+	return defaultModelRegistry
+}
@@ -0,0 +1,215 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/liushuangls/go-anthropic/v2/internal/test/checks"
+)
+
+func TestDefaultModelRegistry(t *testing.T) {
+	registry := anthropic.DefaultModelRegistry()
+
+	entry, ok := registry.Lookup("claude-3-opus-latest")
+	if !ok || entry.ID != anthropic.ModelClaude3Opus20240229 || entry.VertexID != "claude-3-opus@20240229" {
+		t.Fatalf("unexpected entry for an alias: %+v, %v", entry, ok)
+	}
+	if entry.MaxOutputTokens != 4096 || !entry.SupportsTools || entry.SupportsThinking {
+		t.Errorf("unexpected metadata: %+v", entry)
+	}
+
+	entry, ok = registry.Lookup(anthropic.ModelClaude37Sonnet20250219)
+	if !ok || !entry.SupportsThinking {
+		t.Errorf("unexpected entry: %+v, %v", entry, ok)
+	}
+
+	entry = anthropic.ModelEntry{ID: "claude-old", DeprecationDate: time.Date(2024, 9, 4, 0, 0, 0, 0, time.UTC)}
+	if !entry.Deprecated(time.Now()) || entry.Deprecated(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected deprecation: %v", entry.DeprecationDate)
+	}
+
+	if _, ok := registry.Lookup("claude-unknown"); ok {
+		t.Error("an unknown model should not be found")
+	}
+
+	models := registry.Models()
+	if !slices.IsSortedFunc(models, func(a, b anthropic.ModelEntry) int { return strings.Compare(a.ID, b.ID) }) {
+		t.Error("models are not sorted by ID")
+	}
+}
+
+func TestModelRegistryRegister(t *testing.T) {
+	registry, err := anthropic.NewModelRegistry(anthropic.ModelEntry{ID: "model-a", Aliases: []string{"a"}})
+	checks.NoError(t, err, "NewModelRegistry error")
+
+	for _, entry := range []anthropic.ModelEntry{
+		{},
+		{ID: "a"},
+		{ID: "model-b", Aliases: []string{"a"}},
+		{ID: "model-b", Aliases: []string{"model-a"}},
+	} {
+		if err := registry.Register(entry); err == nil {
+			t.Errorf("Register(%+v) should fail", entry)
+		}
+	}
+
+	// registering an entry again replaces it, along with its aliases
+	err = registry.Register(anthropic.ModelEntry{ID: "model-a", Aliases: []string{"new-a"}, MaxOutputTokens: 100})
+	checks.NoError(t, err, "Register error")
+	if _, ok := registry.Lookup("a"); ok {
+		t.Error("the old alias should be removed")
+	}
+	if entry, ok := registry.Lookup("new-a"); !ok || entry.MaxOutputTokens != 100 {
+		t.Errorf("unexpected entry: %+v, %v", entry, ok)
+	}
+	if len(registry.Models()) != 1 {
+		t.Errorf("unexpected models: %+v", registry.Models())
+	}
+}
+
+func TestModelRegistryTranslation(t *testing.T) {
+	var path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		_, _ = w.Write([]byte(`{"type":"message","content":[]}`))
+	}))
+	defer ts.Close()
+
+	registry, err := anthropic.NewModelRegistry(anthropic.ModelEntry{
+		ID:       "claude-custom",
+		Aliases:  []string{"custom"},
+		VertexID: "claude-custom@20250101",
+	})
+	checks.NoError(t, err, "NewModelRegistry error")
+
+	client := anthropic.NewClient("token",
+		anthropic.WithVertexAI("project", "us-east5"),
+		anthropic.WithBaseURL(ts.URL),
+		anthropic.WithModelRegistry(registry),
+	)
+	_, err = client.CreateMessages(context.Background(), anthropic.MessagesRequest{
+		Model:     "custom",
+		Messages:  []anthropic.Message{anthropic.NewUserTextMessage("Hello")},
+		MaxTokens: 100,
+	})
+	checks.NoError(t, err, "CreateMessages error")
+	if path != "/claude-custom@20250101:rawPredict" {
+		t.Errorf("unexpected path: %q", path)
+	}
+}
+
+func TestModelRegistryMaxTokens(t *testing.T) {
+	var requests int
+	var beta string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		beta = r.Header.Get("anthropic-beta")
+		_, _ = w.Write([]byte(`{"type":"message","content":[]}`))
+	}))
+	defer ts.Close()
+
+	request := anthropic.MessagesRequest{
+		Model:     anthropic.ModelClaude35Sonnet20240620,
+		Messages:  []anthropic.Message{anthropic.NewUserTextMessage("Hello")},
+		MaxTokens: 8193,
+	}
+
+	client := anthropic.NewClient("token", anthropic.WithBaseURL(ts.URL))
+	_, err := client.CreateMessages(context.Background(), request)
+	checks.ErrorIs(t, err, anthropic.ErrMaxTokensExceeded, "CreateMessages should check MaxTokens")
+	_, err = client.CreateMessagesStream(context.Background(), anthropic.MessagesStreamRequest{MessagesRequest: request})
+	checks.ErrorIs(t, err, anthropic.ErrMaxTokensExceeded, "CreateMessagesStream should check MaxTokens")
+	if requests != 0 {
+		t.Errorf("%d requests were sent", requests)
+	}
+
+	// without a registry, the API is left to reject the request
+	client = anthropic.NewClient("token", anthropic.WithBaseURL(ts.URL), anthropic.WithModelRegistry(nil))
+	_, err = client.CreateMessages(context.Background(), request)
+	checks.NoError(t, err, "CreateMessages error")
+	if requests != 1 {
+		t.Errorf("%d requests were sent", requests)
+	}
+	if beta != "" {
+		t.Errorf("the default tools beta should only be sent with tools, got %q", beta)
+	}
+
+	// the 128k output beta raises the maximum output tokens
+	request.Model = anthropic.ModelClaude37Sonnet20250219
+	request.MaxTokens = 128000
+	client = anthropic.NewClient("token", anthropic.WithBaseURL(ts.URL),
+		anthropic.WithBetaVersion(anthropic.BetaTools20240516+","+anthropic.BetaOutput128k20250219))
+	_, err = client.CreateMessages(context.Background(), request)
+	checks.NoError(t, err, "CreateMessages error")
+	if requests != 2 {
+		t.Errorf("%d requests were sent", requests)
+	}
+	// the beta is sent even without tools
+	if want := anthropic.BetaTools20240516 + "," + anthropic.BetaOutput128k20250219; beta != want {
+		t.Errorf("anthropic-beta = %q, want %q", beta, want)
+	}
+}
+
+func TestModelRegistryMaxTokensBedrock(t *testing.T) {
+	var body map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"type":"message","content":[]}`))
+	}))
+	defer ts.Close()
+
+	client := anthropic.NewClient("",
+		anthropic.WithBedrock("us-east-1", anthropic.StaticAWSCredentials{AccessKeyID: "id", SecretAccessKey: "secret"}),
+		anthropic.WithBaseURL(ts.URL),
+		anthropic.WithBetaVersion(anthropic.BetaTools20240516+","+anthropic.BetaOutput128k20250219),
+	)
+	_, err := client.CreateMessages(context.Background(), anthropic.MessagesRequest{
+		Model:     anthropic.ModelClaude37Sonnet20250219,
+		Messages:  []anthropic.Message{anthropic.NewUserTextMessage("Hello")},
+		MaxTokens: 128000,
+	})
+	checks.NoError(t, err, "CreateMessages error")
+
+	// Bedrock takes the betas in the body, without those of features generally available
+	want := []any{anthropic.BetaOutput128k20250219}
+	if got, _ := body["anthropic_beta"].([]any); !slices.Equal(got, want) {
+		t.Errorf("anthropic_beta = %v, want %v", body["anthropic_beta"], want)
+	}
+}
+
+func TestModelRegistryBedrockInferenceProfile(t *testing.T) {
+	var path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		_, _ = w.Write([]byte(`{"type":"message","content":[]}`))
+	}))
+	defer ts.Close()
+
+	for region, want := range map[string]string{
+		"us-east-1":    "/model/us.anthropic.claude-3-7-sonnet-20250219-v1%3A0/invoke",
+		"eu-central-1": "/model/eu.anthropic.claude-3-7-sonnet-20250219-v1%3A0/invoke",
+	} {
+		client := anthropic.NewClient("",
+			anthropic.WithBedrock(region, anthropic.StaticAWSCredentials{AccessKeyID: "id", SecretAccessKey: "secret"}),
+			anthropic.WithBaseURL(ts.URL),
+		)
+		_, err := client.CreateMessages(context.Background(), anthropic.MessagesRequest{
+			Model:     anthropic.ModelClaude37Sonnet20250219,
+			Messages:  []anthropic.Message{anthropic.NewUserTextMessage("Hello")},
+			MaxTokens: 100,
+		})
+		checks.NoError(t, err, "CreateMessages error")
+		if path != want {
+			t.Errorf("%s: path = %q, want %q", region, path, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package anthropic
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type ModelInfo struct {
+	Type        string    `json:"type"`
+	ID          string    `json:"id"`
+	DisplayName string    `json:"display_name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ModelResponse struct {
+	httpHeader
+
+	ModelInfo
+}
+
+type ListModelsResponse = Page[ModelInfo]
+
+// ListModels lists the available models, the most recently released first.
+func (c *Client) ListModels(ctx context.Context, params ListParams) (response ListModelsResponse, err error) {
+	req, err := c.newRequest(ctx, http.MethodGet, params.urlSuffix("/models"), nil)
+	if err != nil {
+		return
+	}
+
+	err = c.sendRequest(req, &response)
+	return
+}
+
+// ListAllModels returns an iterator over the models of all pages, starting at params.
+func (c *Client) ListAllModels(ctx context.Context, params ListParams) *PageIterator[ModelInfo] {
+	return newPageIterator(ctx, params, c.ListModels)
+}
+
+// GetModel retrieves a model by ID or alias, aliases are resolved to the model they point to.
+func (c *Client) GetModel(ctx context.Context, modelID string) (response ModelResponse, err error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/models/"+url.PathEscape(modelID), nil)
+	if err != nil {
+		return
+	}
+
+	err = c.sendRequest(req, &response)
+	return
+}
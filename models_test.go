@@ -0,0 +1,135 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/liushuangls/go-anthropic/v2/internal/test"
+	"github.com/liushuangls/go-anthropic/v2/internal/test/checks"
+)
+
+var testModels = []anthropic.ModelInfo{
+	{Type: "model", ID: "claude-3-5-sonnet-20241022", DisplayName: "Claude 3.5 Sonnet (New)", CreatedAt: time.Date(2024, 10, 22, 0, 0, 0, 0, time.UTC)},
+	{Type: "model", ID: "claude-3-5-haiku-20241022", DisplayName: "Claude 3.5 Haiku", CreatedAt: time.Date(2024, 10, 22, 0, 0, 0, 0, time.UTC)},
+	{Type: "model", ID: "claude-3-5-sonnet-20240620", DisplayName: "Claude 3.5 Sonnet (Old)", CreatedAt: time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)},
+}
+
+func newModelsTestClient(t *testing.T) *anthropic.Client {
+	t.Helper()
+
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/models", handleModelsEndpoint)
+	for _, m := range testModels {
+		m := m
+		server.RegisterHandler("/v1/models/"+m.ID, func(w http.ResponseWriter, r *http.Request) {
+			bs, _ := json.Marshal(m)
+			_, _ = w.Write(bs)
+		})
+	}
+	return server.NewClient(t)
+}
+
+func TestListModels(t *testing.T) {
+	client := newModelsTestClient(t)
+
+	page, err := client.ListModels(context.Background(), anthropic.ListParams{Limit: 2})
+	checks.NoError(t, err, "ListModels error")
+	if len(page.Data) != 2 || !page.HasMore || page.LastID != testModels[1].ID {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+
+	page, err = client.ListModels(context.Background(), anthropic.ListParams{AfterID: page.LastID, Limit: 2})
+	checks.NoError(t, err, "ListModels error")
+	if len(page.Data) != 1 || page.HasMore || page.Data[0].ID != testModels[2].ID {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+}
+
+func TestListAllModels(t *testing.T) {
+	client := newModelsTestClient(t)
+
+	var ids []string
+	it := client.ListAllModels(context.Background(), anthropic.ListParams{Limit: 2})
+	for it.Next() {
+		ids = append(ids, it.Current().ID)
+	}
+	checks.NoError(t, it.Err(), "ListAllModels error")
+	if len(ids) != len(testModels) {
+		t.Fatalf("expected %d models, got %v", len(testModels), ids)
+	}
+	for i, m := range testModels {
+		if ids[i] != m.ID {
+			t.Fatalf("unexpected model at %d: %s", i, ids[i])
+		}
+	}
+
+	ids = nil
+	it = client.ListAllModels(context.Background(), anthropic.ListParams{BeforeID: testModels[2].ID, Limit: 1})
+	for it.Next() {
+		ids = append(ids, it.Current().ID)
+	}
+	checks.NoError(t, it.Err(), "ListAllModels backwards error")
+	if len(ids) != 2 || ids[0] != testModels[1].ID || ids[1] != testModels[0].ID {
+		t.Fatalf("unexpected models: %v", ids)
+	}
+}
+
+func TestGetModel(t *testing.T) {
+	client := newModelsTestClient(t)
+
+	resp, err := client.GetModel(context.Background(), "claude-3-5-haiku-20241022")
+	checks.NoError(t, err, "GetModel error")
+	if resp.DisplayName != "Claude 3.5 Haiku" || resp.CreatedAt.IsZero() {
+		t.Fatalf("unexpected model: %+v", resp.ModelInfo)
+	}
+
+	_, err = client.GetModel(context.Background(), "claude-unknown")
+	checks.HasError(t, err, "should error")
+}
+
+func handleModelsEndpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := 20
+	if v := query.Get("limit"); v != "" {
+		limit, _ = strconv.Atoi(v)
+	}
+
+	start, end := 0, len(testModels)
+	for i, m := range testModels {
+		if m.ID == query.Get("after_id") {
+			start = i + 1
+		}
+		if m.ID == query.Get("before_id") {
+			end = i
+		}
+	}
+	if query.Get("before_id") != "" {
+		start = max(end-limit, 0)
+	} else {
+		end = min(start+limit, end)
+	}
+
+	page := anthropic.ListModelsResponse{Data: testModels[start:end]}
+	if len(page.Data) > 0 {
+		page.FirstID = page.Data[0].ID
+		page.LastID = page.Data[len(page.Data)-1].ID
+	}
+	if query.Get("before_id") != "" {
+		page.HasMore = start > 0
+	} else {
+		page.HasMore = end < len(testModels)
+	}
+
+	bs, _ := json.Marshal(page)
+	_, _ = w.Write(bs)
+}
@@ -0,0 +1,115 @@
+package anthropic
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// ListParams are the pagination parameters shared by the list endpoints.
+type ListParams struct {
+	// BeforeID returns the page of results immediately before this object ID.
+	BeforeID string
+	// AfterID returns the page of results immediately after this object ID.
+	AfterID string
+	// Limit is the number of items per page, between 1 and 1000. The API defaults to 20.
+	Limit int
+}
+
+func (p ListParams) urlSuffix(path string) string {
+	query := url.Values{}
+	if p.BeforeID != "" {
+		query.Set("before_id", p.BeforeID)
+	}
+	if p.AfterID != "" {
+		query.Set("after_id", p.AfterID)
+	}
+	if p.Limit > 0 {
+		query.Set("limit", strconv.Itoa(p.Limit))
+	}
+
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// Page is a single page of results returned by a list endpoint.
+type Page[T any] struct {
+	httpHeader
+
+	Data    []T    `json:"data"`
+	HasMore bool   `json:"has_more"`
+	FirstID string `json:"first_id"`
+	LastID  string `json:"last_id"`
+}
+
+// PageIterator iterates over the items of a list endpoint, fetching the following pages as needed.
+// When ListParams.BeforeID is set, it pages backwards, otherwise forwards.
+type PageIterator[T any] struct {
+	ctx    context.Context
+	params ListParams
+	fetch  func(context.Context, ListParams) (Page[T], error)
+
+	page    Page[T]
+	index   int
+	fetched bool
+	err     error
+}
+
+func newPageIterator[T any](ctx context.Context, params ListParams,
+	fetch func(context.Context, ListParams) (Page[T], error)) *PageIterator[T] {
+	return &PageIterator[T]{
+		ctx:    ctx,
+		params: params,
+		fetch:  fetch,
+	}
+}
+
+// Next advances to the next item, it returns false when all pages are consumed or an error occurred.
+func (it *PageIterator[T]) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if it.index+1 < len(it.page.Data) {
+		it.index++
+		return true
+	}
+
+	if it.fetched {
+		if !it.page.HasMore || len(it.page.Data) == 0 {
+			return false
+		}
+		if it.params.BeforeID != "" {
+			it.params.BeforeID = it.page.FirstID
+		} else {
+			it.params.AfterID = it.page.LastID
+		}
+	}
+
+	page, err := it.fetch(it.ctx, it.params)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.page = page
+	it.index = 0
+	it.fetched = true
+	return len(page.Data) > 0
+}
+
+// Current returns the item the iterator is positioned at.
+func (it *PageIterator[T]) Current() T {
+	return it.page.Data[it.index]
+}
+
+// Page returns the page containing the current item.
+func (it *PageIterator[T]) Page() Page[T] {
+	return it.page
+}
+
+// Err returns the error that stopped Next, if any.
+func (it *PageIterator[T]) Err() error {
+	return it.err
+}
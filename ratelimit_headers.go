@@ -1,11 +1,15 @@
 package anthropic
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 )
 
+// RateLimitHeaders are the rate limit headers of a response. The fields of missing headers are zero.
+// The tokens limits are the most restrictive of the input and output tokens limits.
 type RateLimitHeaders struct {
 	// The maximum number of requests allowed within the rate limit window.
 	RequestsLimit int `json:"anthropic-ratelimit-requests-limit"`
@@ -19,28 +23,92 @@ type RateLimitHeaders struct {
 	TokensRemaining int `json:"anthropic-ratelimit-tokens-remaining"`
 	// The time when the token rate limit window will reset, provided in RFC 3339 format.
 	TokensReset time.Time `json:"anthropic-ratelimit-tokens-reset"`
-	// The number of seconds until the rate limit window resets.
-	RetryAfter int `json:"retry-after"`
+	// The maximum number of input tokens allowed within the rate limit window.
+	InputTokensLimit int `json:"anthropic-ratelimit-input-tokens-limit"`
+	// The number of input tokens remaining, rounded to the nearest thousand, within the current rate limit window.
+	InputTokensRemaining int `json:"anthropic-ratelimit-input-tokens-remaining"`
+	// The time when the input token rate limit window will reset, provided in RFC 3339 format.
+	InputTokensReset time.Time `json:"anthropic-ratelimit-input-tokens-reset"`
+	// The maximum number of output tokens allowed within the rate limit window.
+	OutputTokensLimit int `json:"anthropic-ratelimit-output-tokens-limit"`
+	// The number of output tokens remaining, rounded to the nearest thousand, within the current rate limit window.
+	OutputTokensRemaining int `json:"anthropic-ratelimit-output-tokens-remaining"`
+	// The time when the output token rate limit window will reset, provided in RFC 3339 format.
+	OutputTokensReset time.Time `json:"anthropic-ratelimit-output-tokens-reset"`
+	// How long to wait before retrying, sent with 429 responses as a number of seconds or an HTTP date.
+	RetryAfter time.Duration `json:"retry-after"`
 }
 
-func newRateLimitHeaders(h http.Header) RateLimitHeaders {
-	requestsLimit, _ := strconv.Atoi(h.Get("anthropic-ratelimit-requests-limit"))
-	requestsRemaining, _ := strconv.Atoi(h.Get("anthropic-ratelimit-requests-remaining"))
-	requestsReset, _ := time.Parse(time.RFC3339, h.Get("anthropic-ratelimit-requests-reset"))
+// newRateLimitHeaders parses the rate limit headers. Malformed headers are left zero and reported
+// in the returned error, the other fields are set.
+func newRateLimitHeaders(h http.Header) (RateLimitHeaders, error) {
+	p := rateLimitHeaderParser{header: h}
+	headers := RateLimitHeaders{
+		RequestsLimit:         p.int("anthropic-ratelimit-requests-limit"),
+		RequestsRemaining:     p.int("anthropic-ratelimit-requests-remaining"),
+		RequestsReset:         p.time("anthropic-ratelimit-requests-reset"),
+		TokensLimit:           p.int("anthropic-ratelimit-tokens-limit"),
+		TokensRemaining:       p.int("anthropic-ratelimit-tokens-remaining"),
+		TokensReset:           p.time("anthropic-ratelimit-tokens-reset"),
+		InputTokensLimit:      p.int("anthropic-ratelimit-input-tokens-limit"),
+		InputTokensRemaining:  p.int("anthropic-ratelimit-input-tokens-remaining"),
+		InputTokensReset:      p.time("anthropic-ratelimit-input-tokens-reset"),
+		OutputTokensLimit:     p.int("anthropic-ratelimit-output-tokens-limit"),
+		OutputTokensRemaining: p.int("anthropic-ratelimit-output-tokens-remaining"),
+		OutputTokensReset:     p.time("anthropic-ratelimit-output-tokens-reset"),
+	}
+	if v := h.Get("retry-after"); v != "" {
+		d, err := parseRetryAfter(v)
+		if err != nil {
+			p.errs = append(p.errs, fmt.Errorf("invalid retry-after header: %w", err))
+		}
+		headers.RetryAfter = d
+	}
+	return headers, errors.Join(p.errs...)
+}
 
-	tokensLimit, _ := strconv.Atoi(h.Get("anthropic-ratelimit-tokens-limit"))
-	tokensRemaining, _ := strconv.Atoi(h.Get("anthropic-ratelimit-tokens-remaining"))
-	tokensReset, _ := time.Parse(time.RFC3339, h.Get("anthropic-ratelimit-tokens-reset"))
+// rateLimitHeaderParser parses headers, collecting the errors of the malformed ones.
+type rateLimitHeaderParser struct {
+	header http.Header
+	errs   []error
+}
+
+func (p *rateLimitHeaderParser) int(key string) int {
+	v := p.header.Get(key)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("invalid %s header: %w", key, err))
+	}
+	return n
+}
 
-	retryAfter, _ := strconv.Atoi(h.Get("anthropic-ratelimit-retry-after"))
+func (p *rateLimitHeaderParser) time(key string) time.Time {
+	v := p.header.Get(key)
+	if v == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("invalid %s header: %w", key, err))
+	}
+	return t
+}
 
-	return RateLimitHeaders{
-		RequestsLimit:     requestsLimit,
-		RequestsRemaining: requestsRemaining,
-		RequestsReset:     requestsReset,
-		TokensLimit:       tokensLimit,
-		TokensRemaining:   tokensRemaining,
-		TokensReset:       tokensReset,
-		RetryAfter:        retryAfter,
+// parseRetryAfter parses a retry-after header, which holds either a number of seconds or an HTTP date.
+// A date in the past is a zero duration.
+func parseRetryAfter(v string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0, fmt.Errorf("negative number of seconds %d", seconds)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	date, err := http.ParseTime(v)
+	if err != nil {
+		return 0, err
 	}
+	return max(time.Until(date), 0), nil
 }
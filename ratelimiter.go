@@ -0,0 +1,258 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitImageTokens is the estimated input tokens of an image, those of a 1.15 megapixel image.
+	rateLimitImageTokens = 1600
+	// rateLimitPDFPageTokens is the estimated input tokens of a page of a PDF document, its text and image.
+	rateLimitPDFPageTokens = 3000
+)
+
+// pdfPageRegexp matches the page objects of a PDF document, not the page tree nodes (/Type /Pages).
+var pdfPageRegexp = regexp.MustCompile(`/Type\s*/Page([^s]|$)`)
+
+// RateLimits are the per-minute limits a RateLimiter enforces. Zero means unknown: the limit
+// is not enforced until the rate limit headers of a response tell it.
+type RateLimits struct {
+	RequestsPerMinute     int
+	InputTokensPerMinute  int
+	OutputTokensPerMinute int
+}
+
+// RateLimiter delays the requests of clients so they stay within the rate limits of the API,
+// instead of being rejected with 429 errors. It tracks the requests and the input and output tokens
+// per minute as token buckets, continuously replenished as the API does, and corrects them from the
+// anthropic-ratelimit-* headers of every response. It is safe for concurrent use, and can be shared
+// by the clients of an organization.
+//
+// The tokens of a request are estimated before sending it: the input tokens as a quarter of the
+// length of its JSON body, the base64 data of its images and documents excluded, plus a flat
+// cost per image and PDF page, and the output tokens as its MaxTokens.
+type RateLimiter struct {
+	mu           sync.Mutex
+	requests     tokenBucket
+	inputTokens  tokenBucket
+	outputTokens tokenBucket
+	// pausedUntil is set from the retry-after header of a 429 response
+	pausedUntil time.Time
+}
+
+// NewRateLimiter returns a limiter with the limits, starting with full buckets.
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	now := time.Now()
+	l := &RateLimiter{}
+	l.requests.setLimit(limits.RequestsPerMinute, now)
+	l.inputTokens.setLimit(limits.InputTokensPerMinute, now)
+	l.outputTokens.setLimit(limits.OutputTokensPerMinute, now)
+	return l
+}
+
+// WithRateLimiter makes the client wait for the limiter before sending each request, retries included.
+func WithRateLimiter(limiter *RateLimiter) ClientOption {
+	return func(c *ClientConfig) {
+		c.RateLimiter = limiter
+	}
+}
+
+// Wait blocks until a request with the given numbers of tokens can be sent, and takes them from the
+// buckets. A request needing more tokens than a limit waits for a full bucket. It returns the error
+// of the context if it is done first.
+func (l *RateLimiter) Wait(ctx context.Context, inputTokens, outputTokens int) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.requests.refill(now)
+		l.inputTokens.refill(now)
+		l.outputTokens.refill(now)
+
+		wait := max(
+			l.pausedUntil.Sub(now),
+			l.requests.wait(1),
+			l.inputTokens.wait(float64(inputTokens)),
+			l.outputTokens.wait(float64(outputTokens)),
+		)
+		if wait <= 0 {
+			l.requests.take(1)
+			l.inputTokens.take(float64(inputTokens))
+			l.outputTokens.take(float64(outputTokens))
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// update corrects the buckets from the rate limit headers of the response, and pauses
+// all requests until the retry-after time of a 429 response.
+func (l *RateLimiter) update(resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.requests.update(resp.Header, "requests", now)
+	l.inputTokens.update(resp.Header, "input-tokens", now)
+	l.outputTokens.update(resp.Header, "output-tokens", now)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if d, ok := retryAfter(resp.Header); ok && now.Add(d).After(l.pausedUntil) {
+			l.pausedUntil = now.Add(d)
+		}
+	}
+}
+
+// tokenBucket holds up to limit tokens, replenished at limit tokens per minute.
+type tokenBucket struct {
+	// limit is zero if unknown, the bucket is then not enforced
+	limit     float64
+	available float64
+	updated   time.Time
+}
+
+func (b *tokenBucket) setLimit(limit int, now time.Time) {
+	if b.limit == 0 {
+		b.available = float64(limit)
+	}
+	b.limit = float64(limit)
+	b.available = min(b.available, b.limit)
+	b.updated = now
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if b.limit == 0 {
+		return
+	}
+	b.available = min(b.limit, b.available+now.Sub(b.updated).Minutes()*b.limit)
+	b.updated = now
+}
+
+// wait returns how long until n tokens are available, or the bucket is full if n exceeds the limit.
+func (b *tokenBucket) wait(n float64) time.Duration {
+	n = min(n, b.limit)
+	if b.limit == 0 || b.available >= n {
+		return 0
+	}
+	return time.Duration((n - b.available) / b.limit * float64(time.Minute))
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b.limit == 0 {
+		return
+	}
+	b.available -= min(n, b.limit)
+}
+
+// update sets the limit and the available tokens from the anthropic-ratelimit-<family>-* headers.
+func (b *tokenBucket) update(header http.Header, family string, now time.Time) {
+	if limit, err := strconv.Atoi(header.Get("anthropic-ratelimit-" + family + "-limit")); err == nil && limit > 0 {
+		b.refill(now)
+		b.setLimit(limit, now)
+	}
+	if b.limit == 0 {
+		return
+	}
+	if remaining, err := strconv.Atoi(header.Get("anthropic-ratelimit-" + family + "-remaining")); err == nil {
+		b.available = min(float64(remaining), b.limit)
+		b.updated = now
+	}
+}
+
+type rateLimitCostKey struct{}
+
+// rateLimitCost is the estimated tokens of a request, passed to doRequest in the request context.
+type rateLimitCost struct {
+	inputTokens  int
+	outputTokens int
+}
+
+// withRateLimitCost returns a context holding the estimated tokens of the request with the body.
+// Only messages and completions count against the token limits.
+func withRateLimitCost(ctx context.Context, body any, reqBody []byte) context.Context {
+	var cost rateLimitCost
+	switch body := body.(type) {
+	case *MessagesRequest:
+		cost = rateLimitCost{inputTokens: estimateInputTokens(body.Messages, reqBody), outputTokens: body.MaxTokens}
+	case *CompleteRequest:
+		cost = rateLimitCost{inputTokens: len(reqBody) / 4, outputTokens: body.MaxTokensToSample}
+	}
+	return context.WithValue(ctx, rateLimitCostKey{}, cost)
+}
+
+func rateLimitCostFromContext(ctx context.Context) rateLimitCost {
+	cost, _ := ctx.Value(rateLimitCostKey{}).(rateLimitCost)
+	return cost
+}
+
+// estimateInputTokens estimates the input tokens of the messages sent in the JSON body.
+func estimateInputTokens(messages []Message, body []byte) int {
+	size, tokens := len(body), 0
+	for _, message := range messages {
+		for _, content := range message.Content {
+			dataSize, mediaTokens := estimateMediaTokens(content)
+			size -= dataSize
+			tokens += mediaTokens
+		}
+	}
+	return max(size, 0)/4 + tokens
+}
+
+// estimateMediaTokens returns the size of the base64 data of the images and documents in content,
+// and their estimated input tokens.
+func estimateMediaTokens(content MessageContent) (dataSize, tokens int) {
+	if content.MessageContentToolResult != nil {
+		for _, c := range content.MessageContentToolResult.Content {
+			s, t := estimateMediaTokens(c)
+			dataSize, tokens = dataSize+s, tokens+t
+		}
+	}
+
+	source := content.Source
+	if source == nil {
+		return dataSize, tokens
+	}
+	for _, c := range source.Content {
+		s, t := estimateMediaTokens(c)
+		dataSize, tokens = dataSize+s, tokens+t
+	}
+
+	if source.Type == MessagesContentSourceTypeBase64 {
+		switch data := source.Data.(type) {
+		case string:
+			dataSize += len(data)
+		case []byte:
+			dataSize += base64.StdEncoding.EncodedLen(len(data))
+		}
+	}
+
+	switch {
+	case content.Type == MessagesContentTypeImage:
+		tokens += rateLimitImageTokens
+	case content.Type == MessagesContentTypeDocument && source.MediaType == "application/pdf":
+		tokens += countPDFPages(source) * rateLimitPDFPageTokens
+	}
+	return dataSize, tokens
+}
+
+// countPDFPages counts the pages of a base64 PDF source, one if it is not known.
+func countPDFPages(source *MessageContentSource) int {
+	var data []byte
+	switch d := source.Data.(type) {
+	case string:
+		data, _ = base64.StdEncoding.DecodeString(d)
+	case []byte:
+		data = d
+	}
+	return max(len(pdfPageRegexp.FindAllIndex(data, -1)), 1)
+}
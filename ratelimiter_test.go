@@ -0,0 +1,197 @@
+package anthropic_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/liushuangls/go-anthropic/v2/internal/test/checks"
+)
+
+func waitWithTimeout(limiter *anthropic.RateLimiter, inputTokens, outputTokens int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	return limiter.Wait(ctx, inputTokens, outputTokens)
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	limiter := anthropic.NewRateLimiter(anthropic.RateLimits{RequestsPerMinute: 1, InputTokensPerMinute: 1000})
+
+	checks.NoError(t, waitWithTimeout(limiter, 600, 0), "the first request should not wait")
+	err := waitWithTimeout(limiter, 0, 0)
+	checks.ErrorIs(t, err, context.DeadlineExceeded, "the second request should wait for a minute")
+
+	limiter = anthropic.NewRateLimiter(anthropic.RateLimits{InputTokensPerMinute: 1000})
+	checks.NoError(t, waitWithTimeout(limiter, 600, 0), "the first request should not wait")
+	err = waitWithTimeout(limiter, 600, 0)
+	checks.ErrorIs(t, err, context.DeadlineExceeded, "the input tokens should be exhausted")
+
+	// a request larger than the limit only waits for a full bucket
+	limiter = anthropic.NewRateLimiter(anthropic.RateLimits{OutputTokensPerMinute: 1000})
+	checks.NoError(t, waitWithTimeout(limiter, 0, 5000), "a full bucket should be enough")
+
+	// unknown limits are not enforced
+	limiter = anthropic.NewRateLimiter(anthropic.RateLimits{})
+	for i := 0; i < 100; i++ {
+		checks.NoError(t, waitWithTimeout(limiter, 100000, 100000), "unknown limits should not wait")
+	}
+}
+
+// newRateLimitTestServer returns a server responding with the headers, counting the requests.
+func newRateLimitTestServer(t *testing.T, header http.Header, statusCode int) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(statusCode)
+		if statusCode == http.StatusOK {
+			_, _ = w.Write([]byte(`{"type":"message","content":[]}`))
+		} else {
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"Rate limited"}}`))
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &requests
+}
+
+func TestRateLimiterHeaders(t *testing.T) {
+	// 1200 requests per minute replenish one request every 50ms
+	ts, _ := newRateLimitTestServer(t, http.Header{
+		"Anthropic-Ratelimit-Requests-Limit":     {"1200"},
+		"Anthropic-Ratelimit-Requests-Remaining": {"0"},
+	}, http.StatusOK)
+
+	client := anthropic.NewClient("token",
+		anthropic.WithBaseURL(ts.URL),
+		anthropic.WithRateLimiter(anthropic.NewRateLimiter(anthropic.RateLimits{})),
+	)
+
+	_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.NoError(t, err, "CreateMessages error")
+
+	start := time.Now()
+	_, err = client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.NoError(t, err, "CreateMessages error")
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("the request should wait for the bucket to be replenished, waited %s", elapsed)
+	}
+}
+
+func TestRateLimiterOutputTokensEstimate(t *testing.T) {
+	// 600000 output tokens per minute replenish 1000 tokens every 100ms
+	ts, requests := newRateLimitTestServer(t, http.Header{
+		"Anthropic-Ratelimit-Output-Tokens-Limit":     {"600000"},
+		"Anthropic-Ratelimit-Output-Tokens-Remaining": {"0"},
+	}, http.StatusOK)
+
+	client := anthropic.NewClient("token",
+		anthropic.WithBaseURL(ts.URL),
+		anthropic.WithRateLimiter(anthropic.NewRateLimiter(anthropic.RateLimits{})),
+	)
+	_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.NoError(t, err, "CreateMessages error")
+
+	request := newTestMessagesRequest()
+	request.MaxTokens = 1000
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = client.CreateMessages(ctx, request)
+	checks.ErrorIs(t, err, context.DeadlineExceeded, "the request should wait for its MaxTokens")
+
+	request.MaxTokens = 10
+	_, err = client.CreateMessages(context.Background(), request)
+	checks.NoError(t, err, "CreateMessages error")
+	if n := requests.Load(); n != 2 {
+		t.Errorf("%d requests were sent, want 2", n)
+	}
+}
+
+func TestRateLimiterImageEstimate(t *testing.T) {
+	ts, requests := newRateLimitTestServer(t, http.Header{}, http.StatusOK)
+
+	// 10000 input tokens per minute are enough for a few images, not megabytes of text
+	client := anthropic.NewClient("token",
+		anthropic.WithBaseURL(ts.URL),
+		anthropic.WithRateLimiter(anthropic.NewRateLimiter(anthropic.RateLimits{InputTokensPerMinute: 10000})),
+	)
+
+	request := newTestMessagesRequest()
+	request.Messages = []anthropic.Message{{
+		Role: anthropic.RoleUser,
+		Content: []anthropic.MessageContent{
+			anthropic.NewImageMessageContent(anthropic.MessageContentSource{
+				Type:      anthropic.MessagesContentSourceTypeBase64,
+				MediaType: "image/png",
+				Data:      base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0}, 1024*1024)),
+			}),
+			anthropic.NewTextMessageContent("What is in this image?"),
+		},
+	}}
+	for i := 0; i < 3; i++ {
+		// megabytes of text would wait for a minute
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := client.CreateMessages(ctx, request)
+		cancel()
+		checks.NoError(t, err, "the image request should not be throttled")
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("%d requests were sent, want 3", n)
+	}
+}
+
+func TestRateLimiterRetryAfter(t *testing.T) {
+	ts, requests := newRateLimitTestServer(t, http.Header{"Retry-After": {"10"}}, http.StatusTooManyRequests)
+
+	client := anthropic.NewClient("token",
+		anthropic.WithBaseURL(ts.URL),
+		anthropic.WithRateLimiter(anthropic.NewRateLimiter(anthropic.RateLimits{})),
+	)
+	_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	var apiErr *anthropic.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsRateLimitErr() {
+		t.Fatalf("expected a rate limit error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.CreateMessages(ctx, newTestMessagesRequest())
+	checks.ErrorIs(t, err, context.DeadlineExceeded, "the requests should be paused until the retry-after time")
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests were sent, want 1", n)
+	}
+}
+
+func TestRateLimiterConcurrent(t *testing.T) {
+	ts, requests := newRateLimitTestServer(t, http.Header{
+		"Anthropic-Ratelimit-Requests-Limit":     {"6000"},
+		"Anthropic-Ratelimit-Requests-Remaining": {"10"},
+	}, http.StatusOK)
+
+	limiter := anthropic.NewRateLimiter(anthropic.RateLimits{RequestsPerMinute: 6000, InputTokensPerMinute: 1000000})
+	client := anthropic.NewClient("token", anthropic.WithBaseURL(ts.URL), anthropic.WithRateLimiter(limiter))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+			checks.NoError(t, err, "CreateMessages error")
+		}()
+	}
+	wg.Wait()
+
+	if n := requests.Load(); n != 20 {
+		t.Errorf("%d requests were sent, want 20", n)
+	}
+}
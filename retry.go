@@ -0,0 +1,153 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RetryPolicy configures how failed requests are retried.
+// Streaming requests are only retried while the stream is being established, that is before
+// any event has been delivered to the caller, including when the first event of a successful
+// response is an error event; errors after that point are returned as is.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values less than 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. It doubles with every following attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts, including delays requested by the server.
+	// Zero means no cap.
+	MaxDelay time.Duration
+	// Jitter is the fraction, between 0 and 1, of the computed backoff that is randomized.
+	Jitter float64
+	// Retryable reports whether a failed attempt should be retried.
+	// If nil, IsRetryableError is used.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy returns a RetryPolicy with sensible defaults for the Anthropic API.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// IsRetryableError reports whether err is worth retrying: rate limit, overloaded and
+// internal api errors, 429 and 5xx responses, and transport errors.
+func IsRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Retryable()
+	}
+
+	if errType, ok := errorType(err); ok {
+		return isRetryableErrType(errType)
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+func (p *RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryableError(err)
+}
+
+// delay returns how long to wait after the given failed attempt. Hints sent by the server,
+// the retry-after header or the rate limit reset time, take precedence over the backoff.
+func (p *RetryPolicy) delay(attempt int, err error, header http.Header) time.Duration {
+	d, ok := retryAfter(header)
+	if !ok {
+		if errType, _ := errorType(err); errType == ErrTypeRateLimit {
+			rateLimitHeaders, _ := newRateLimitHeaders(header)
+			if reset := rateLimitHeaders.RequestsReset; !reset.IsZero() {
+				d = time.Until(reset)
+				ok = d > 0
+			}
+		}
+	}
+	if !ok {
+		d = p.backoff(attempt)
+	}
+
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay
+	// without MaxDelay, doubling stops before the duration overflows
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay) && d <= math.MaxInt64/2; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// retryAfter returns the delay of the retry-after header, if it is set and valid.
+func retryAfter(header http.Header) (time.Duration, bool) {
+	v := header.Get("retry-after")
+	if v == "" {
+		return 0, false
+	}
+	d, err := parseRetryAfter(v)
+	return d, err == nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rewindRequest returns a copy of req with a fresh body, so it can be sent again.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("request body can not be rewound for retry")
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
+}
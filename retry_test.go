@@ -0,0 +1,282 @@
+package anthropic_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/liushuangls/go-anthropic/v2/internal/test"
+	"github.com/liushuangls/go-anthropic/v2/internal/test/checks"
+)
+
+var testRetryPolicy = anthropic.RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    10 * time.Millisecond,
+}
+
+// newMessagesTestClient returns a client of a test server handling the messages endpoint.
+func newMessagesTestClient(t *testing.T, handler func(w http.ResponseWriter, r *http.Request), opts ...anthropic.ClientOption) *anthropic.Client {
+	t.Helper()
+
+	server := test.NewTestServer()
+	server.RegisterHandler("/v1/messages", handler)
+	return server.NewClient(t, opts...)
+}
+
+// failingHandler fails the first n requests with the given status and error type, then delegates to next.
+func failingHandler(n int32, status int, errType anthropic.ErrType, header map[string]string, calls *atomic.Int32,
+	next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= n {
+			for k, v := range header {
+				w.Header().Set(k, v)
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"` + string(errType) + `","message":"failed"}}`))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func newTestMessagesRequest() anthropic.MessagesRequest {
+	return anthropic.MessagesRequest{
+		Model: anthropic.ModelClaude3Haiku20240307,
+		Messages: []anthropic.Message{
+			anthropic.NewUserTextMessage("What is your name?"),
+		},
+		MaxTokens: 1000,
+	}
+}
+
+func TestRetryOverloaded(t *testing.T) {
+	var calls atomic.Int32
+	client := newMessagesTestClient(t,
+		failingHandler(2, 529, anthropic.ErrTypeOverloaded, nil, &calls, handleMessagesEndpoint),
+		anthropic.WithRetryPolicy(testRetryPolicy),
+	)
+
+	resp, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.NoError(t, err, "CreateMessages error")
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load())
+	}
+	if resp.GetFirstContentText() != "hello" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	var calls atomic.Int32
+	client := newMessagesTestClient(t,
+		failingHandler(10, http.StatusTooManyRequests, anthropic.ErrTypeRateLimit, nil, &calls, handleMessagesEndpoint),
+		anthropic.WithRetryPolicy(testRetryPolicy),
+	)
+
+	_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.HasError(t, err, "should error")
+
+	var e *anthropic.APIError
+	if !errors.As(err, &e) || !e.IsRateLimitErr() {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	var calls atomic.Int32
+	client := newMessagesTestClient(t,
+		failingHandler(10, http.StatusBadRequest, anthropic.ErrTypeInvalidRequest, nil, &calls, handleMessagesEndpoint),
+		anthropic.WithRetryPolicy(testRetryPolicy),
+	)
+
+	_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.HasError(t, err, "should error")
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 attempt, got %d", calls.Load())
+	}
+}
+
+func TestRetryDisabledByDefault(t *testing.T) {
+	var calls atomic.Int32
+	client := newMessagesTestClient(t,
+		failingHandler(1, 529, anthropic.ErrTypeOverloaded, nil, &calls, handleMessagesEndpoint),
+	)
+
+	_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.HasError(t, err, "should error")
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 attempt, got %d", calls.Load())
+	}
+}
+
+func TestRetryCustomPredicate(t *testing.T) {
+	var calls atomic.Int32
+	policy := testRetryPolicy
+	policy.Retryable = func(err error) bool {
+		var e *anthropic.APIError
+		return errors.As(err, &e) && e.IsInvalidRequestErr()
+	}
+	client := newMessagesTestClient(t,
+		failingHandler(1, http.StatusBadRequest, anthropic.ErrTypeInvalidRequest, nil, &calls, handleMessagesEndpoint),
+		anthropic.WithRetryPolicy(policy),
+	)
+
+	_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.NoError(t, err, "CreateMessages error")
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 attempts, got %d", calls.Load())
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	policy := testRetryPolicy
+	policy.MaxDelay = 0
+	client := newMessagesTestClient(t,
+		failingHandler(1, http.StatusTooManyRequests, anthropic.ErrTypeRateLimit, map[string]string{"retry-after": "1"},
+			&calls, handleMessagesEndpoint),
+		anthropic.WithRetryPolicy(policy),
+	)
+
+	start := time.Now()
+	_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.NoError(t, err, "CreateMessages error")
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected to wait for retry-after, waited %s", elapsed)
+	}
+}
+
+func TestRetryContextCanceled(t *testing.T) {
+	var calls atomic.Int32
+	policy := testRetryPolicy
+	policy.MaxDelay = 0
+	client := newMessagesTestClient(t,
+		failingHandler(1, http.StatusTooManyRequests, anthropic.ErrTypeRateLimit, map[string]string{"retry-after": "60"},
+			&calls, handleMessagesEndpoint),
+		anthropic.WithRetryPolicy(policy),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.CreateMessages(ctx, newTestMessagesRequest())
+	checks.ErrorIs(t, err, context.DeadlineExceeded, "should be canceled by context")
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 attempt, got %d", calls.Load())
+	}
+}
+
+func TestRetryMessagesStream(t *testing.T) {
+	var calls atomic.Int32
+	client := newMessagesTestClient(t,
+		failingHandler(1, 529, anthropic.ErrTypeOverloaded, nil, &calls, handlerMessagesStream),
+		anthropic.WithRetryPolicy(testRetryPolicy),
+	)
+
+	var received string
+	resp, err := client.CreateMessagesStream(context.Background(), anthropic.MessagesStreamRequest{
+		MessagesRequest: newTestMessagesRequest(),
+		OnContentBlockDelta: func(data anthropic.MessagesEventContentBlockDeltaData) {
+			received += data.Delta.GetText()
+		},
+	})
+	checks.NoError(t, err, "CreateMessagesStream error")
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 attempts, got %d", calls.Load())
+	}
+
+	expected := strings.Join(testMessagesStreamContent, "")
+	if received != expected || resp.GetFirstContentText() != expected {
+		t.Fatalf("expected %q, got %q", expected, received)
+	}
+}
+
+func TestRetryMessagesStreamErrorEvent(t *testing.T) {
+	var calls atomic.Int32
+	client := newMessagesTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("event: error\n" +
+				`data: {"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}` + "\n\n"))
+			return
+		}
+		handlerMessagesStream(w, r)
+	}, anthropic.WithRetryPolicy(testRetryPolicy))
+
+	var errorEvents int
+	resp, err := client.CreateMessagesStream(context.Background(), anthropic.MessagesStreamRequest{
+		MessagesRequest: newTestMessagesRequest(),
+		OnError: func(anthropic.ErrorResponse) {
+			errorEvents++
+		},
+	})
+	checks.NoError(t, err, "CreateMessagesStream error")
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 attempts, got %d", calls.Load())
+	}
+	if errorEvents != 0 {
+		t.Fatalf("the retried error event should not be delivered, got %d", errorEvents)
+	}
+	if expected := strings.Join(testMessagesStreamContent, ""); resp.GetFirstContentText() != expected {
+		t.Fatalf("expected %q, got %q", expected, resp.GetFirstContentText())
+	}
+}
+
+func TestRetryMessagesStreamEstablished(t *testing.T) {
+	var calls atomic.Int32
+	client := newMessagesTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("event: message_start\n" +
+			`data: {"type":"message_start","message":{"id":"1","type":"message","role":"assistant","content":[],"model":"claude-3-haiku-20240307","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":14,"output_tokens":1}}}` + "\n\n" +
+			"event: error\n" +
+			`data: {"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}` + "\n\n"))
+	}, anthropic.WithRetryPolicy(testRetryPolicy))
+
+	_, err := client.CreateMessagesStream(context.Background(), anthropic.MessagesStreamRequest{
+		MessagesRequest: newTestMessagesRequest(),
+	})
+	checks.HasError(t, err, "should error")
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 attempt, got %d", calls.Load())
+	}
+}
+
+func TestRetryMessagesStreamErrorEventExhausted(t *testing.T) {
+	var calls atomic.Int32
+	client := newMessagesTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		handlerMessagesStream(w, r)
+	}, anthropic.WithRetryPolicy(testRetryPolicy))
+
+	// the error event of the last attempt is delivered as without retries
+	var errorEvents int
+	request := anthropic.MessagesStreamRequest{
+		MessagesRequest: newTestMessagesRequest(),
+		OnError: func(anthropic.ErrorResponse) {
+			errorEvents++
+		},
+	}
+	request.SetTemperature(2)
+	_, err := client.CreateMessagesStream(context.Background(), request)
+
+	var e *anthropic.APIError
+	if !errors.As(err, &e) || !e.IsOverloadedErr() {
+		t.Fatalf("expected overloaded error, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load())
+	}
+	if errorEvents != 1 {
+		t.Fatalf("expected 1 error event, got %d", errorEvents)
+	}
+}
@@ -0,0 +1,323 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2/jsonschema"
+)
+
+const (
+	defaultToolRunnerMaxIterations = 10
+	defaultToolTimeout             = time.Minute
+	defaultToolConcurrency         = 8
+)
+
+var ErrToolRunnerMaxIterations = errors.New("tool runner reached the maximum number of iterations")
+
+// ToolHandler executes a tool call with the input sent by the model and returns the content of the tool result.
+// A returned error is sent back to the model as an is_error tool result.
+type ToolHandler func(ctx context.Context, input json.RawMessage) ([]MessageContent, error)
+
+// TextToolHandler adapts a handler returning text to a ToolHandler.
+func TextToolHandler(fn func(ctx context.Context, input json.RawMessage) (string, error)) ToolHandler {
+	return func(ctx context.Context, input json.RawMessage) ([]MessageContent, error) {
+		text, err := fn(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		return []MessageContent{NewTextMessageContent(text)}, nil
+	}
+}
+
+// Tool is a tool definition along with the handler executing its calls.
+type Tool struct {
+	ToolDefinition
+	Handler ToolHandler
+	// Timeout bounds a single call of the handler, the runner's tool timeout is used if zero.
+	Timeout time.Duration
+}
+
+// NewTool creates a tool calling fn, with the input schema generated from the In type with jsonschema.Reflect.
+// The input is decoded into In, and the output is sent as text if it is a string, as is if it is a
+// MessageContent or []MessageContent, or else as its JSON encoding.
+func NewTool[In, Out any](name, description string, fn func(ctx context.Context, input In) (Out, error)) (Tool, error) {
+	var zero In
+	schema, err := jsonschema.GenerateSchemaForType(reflect.TypeOf(&zero).Elem())
+	if err != nil {
+		return Tool{}, fmt.Errorf("tool %q: %w", name, err)
+	}
+	if schema.Type != jsonschema.Object {
+		return Tool{}, fmt.Errorf("tool %q: input must be an object, got %s", name, schema.Type)
+	}
+
+	handler := func(ctx context.Context, raw json.RawMessage) ([]MessageContent, error) {
+		var input In
+		if err := json.Unmarshal(raw, &input); err != nil {
+			return nil, err
+		}
+		output, err := fn(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		return toolOutputContent(output)
+	}
+
+	return Tool{
+		ToolDefinition: ToolDefinition{
+			Name:        name,
+			Description: description,
+			InputSchema: *schema,
+		},
+		Handler: handler,
+	}, nil
+}
+
+func toolOutputContent(output any) ([]MessageContent, error) {
+	switch output := output.(type) {
+	case string:
+		return []MessageContent{NewTextMessageContent(output)}, nil
+	case MessageContent:
+		return []MessageContent{output}, nil
+	case []MessageContent:
+		return output, nil
+	default:
+		bs, err := json.Marshal(output)
+		if err != nil {
+			return nil, err
+		}
+		return []MessageContent{NewTextMessageContent(string(bs))}, nil
+	}
+}
+
+type ToolRunnerOption func(*ToolRunner)
+
+// WithMaxIterations sets the maximum number of messages requests of a run, 10 by default.
+func WithMaxIterations(n int) ToolRunnerOption {
+	return func(r *ToolRunner) {
+		r.maxIterations = n
+	}
+}
+
+// WithToolTimeout sets the timeout of the tools without their own, 1 minute by default.
+func WithToolTimeout(timeout time.Duration) ToolRunnerOption {
+	return func(r *ToolRunner) {
+		r.toolTimeout = timeout
+	}
+}
+
+// WithToolConcurrency sets the maximum number of tool calls of a turn executed concurrently, 8 by default.
+func WithToolConcurrency(n int) ToolRunnerOption {
+	return func(r *ToolRunner) {
+		r.toolConcurrency = n
+	}
+}
+
+// ToolRunner sends messages requests and executes the tool calls of the responses with the registered
+// tools, until the model stops asking for tools.
+type ToolRunner struct {
+	client          *Client
+	tools           map[string]Tool
+	definitions     []ToolDefinition
+	maxIterations   int
+	toolTimeout     time.Duration
+	toolConcurrency int
+}
+
+func NewToolRunner(client *Client, opts ...ToolRunnerOption) *ToolRunner {
+	r := &ToolRunner{
+		client:          client,
+		tools:           make(map[string]Tool),
+		maxIterations:   defaultToolRunnerMaxIterations,
+		toolTimeout:     defaultToolTimeout,
+		toolConcurrency: defaultToolConcurrency,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Register adds tools to the runner, their names must be unique.
+func (r *ToolRunner) Register(tools ...Tool) error {
+	for _, tool := range tools {
+		if tool.Name == "" {
+			return errors.New("tool name is empty")
+		}
+		if tool.Handler == nil {
+			return fmt.Errorf("tool %q has no handler", tool.Name)
+		}
+		if _, ok := r.tools[tool.Name]; ok {
+			return fmt.Errorf("tool %q is already registered", tool.Name)
+		}
+		r.tools[tool.Name] = tool
+		r.definitions = append(r.definitions, tool.ToolDefinition)
+	}
+	return nil
+}
+
+// ToolRunResult is the outcome of a run.
+type ToolRunResult struct {
+	// Messages is the transcript of the run: the request messages followed by
+	// the assistant responses and the tool results.
+	Messages []Message
+	// Response is the last response received.
+	Response MessagesResponse
+	// Usage is the usage summed over all the requests.
+	Usage MessagesUsage
+	// Iterations is the number of requests sent.
+	Iterations int
+}
+
+// Run sends the request and executes the requested tool calls until the response stops for another
+// reason than tool use. The registered tools are sent if the request has no Tools.
+// The result is returned along with an error, e.g. ErrToolRunnerMaxIterations, so the transcript can be inspected.
+func (r *ToolRunner) Run(ctx context.Context, request MessagesRequest) (result ToolRunResult, err error) {
+	if len(request.Tools) == 0 {
+		request.Tools = r.definitions
+	}
+	request.Messages = append([]Message(nil), request.Messages...)
+
+	for {
+		if result.Iterations >= r.maxIterations {
+			result.Messages = request.Messages
+			return result, ErrToolRunnerMaxIterations
+		}
+
+		resp, err := r.client.CreateMessages(ctx, request)
+		if err != nil {
+			result.Messages = request.Messages
+			return result, err
+		}
+		result.Iterations++
+		result.Response = resp
+		result.Usage = addUsage(result.Usage, resp.Usage)
+		request.Messages = append(request.Messages, resp.ToMessage())
+
+		if resp.StopReason != MessagesStopReasonToolUse {
+			result.Messages = request.Messages
+			return result, nil
+		}
+
+		request.Messages = append(request.Messages, r.ExecuteToolCalls(ctx, resp))
+	}
+}
+
+// ExecuteToolCalls executes the tool_use blocks of the response concurrently, and returns
+// the user message with their tool results, in the order of the calls.
+func (r *ToolRunner) ExecuteToolCalls(ctx context.Context, response MessagesResponse) Message {
+	var calls []MessageContentToolUse
+	for _, content := range response.Content {
+		if content.Type == MessagesContentTypeToolUse && content.MessageContentToolUse != nil {
+			calls = append(calls, *content.MessageContentToolUse)
+		}
+	}
+
+	results := make([]MessageContent, len(calls))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(max(r.toolConcurrency, 1), len(calls)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = r.runTool(ctx, calls[i])
+			}
+		}()
+	}
+	for i := range calls {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return Message{Role: RoleUser, Content: results}
+}
+
+// runTool executes a tool call and returns its tool result, failures being reported as is_error results.
+func (r *ToolRunner) runTool(ctx context.Context, toolUse MessageContentToolUse) MessageContent {
+	content, err := r.callTool(ctx, toolUse)
+	if err != nil {
+		return NewToolResultMessageContent(toolUse.ID, err.Error(), true)
+	}
+
+	isError := false
+	return MessageContent{
+		Type: MessagesContentTypeToolResult,
+		MessageContentToolResult: &MessageContentToolResult{
+			ToolUseID: &toolUse.ID,
+			Content:   content,
+			IsError:   &isError,
+		},
+	}
+}
+
+func (r *ToolRunner) callTool(ctx context.Context, toolUse MessageContentToolUse) ([]MessageContent, error) {
+	tool, ok := r.tools[toolUse.Name]
+	if !ok {
+		return nil, fmt.Errorf("tool %q not found", toolUse.Name)
+	}
+	if err := validateToolInput(tool.InputSchema, toolUse.Input); err != nil {
+		return nil, fmt.Errorf("invalid input for tool %q: %w", toolUse.Name, err)
+	}
+
+	timeout := tool.Timeout
+	if timeout == 0 {
+		timeout = r.toolTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type toolResult struct {
+		content []MessageContent
+		err     error
+	}
+	done := make(chan toolResult, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- toolResult{err: fmt.Errorf("tool %q panicked: %v", toolUse.Name, p)}
+			}
+		}()
+		content, err := tool.Handler(ctx, toolUse.Input)
+		done <- toolResult{content: content, err: err}
+	}()
+
+	// a handler ignoring its context is abandoned once the timeout expires
+	select {
+	case res := <-done:
+		return res.content, res.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("tool %q timed out after %s", toolUse.Name, timeout)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// validateToolInput validates the input against the tool's schema if it is a jsonschema.Definition.
+func validateToolInput(schema any, input json.RawMessage) error {
+	switch schema := schema.(type) {
+	case jsonschema.Definition:
+		return schema.Validate(input)
+	case *jsonschema.Definition:
+		if schema != nil {
+			return schema.Validate(input)
+		}
+	}
+	return nil
+}
+
+func addUsage(a, b MessagesUsage) MessagesUsage {
+	return MessagesUsage{
+		InputTokens:              a.InputTokens + b.InputTokens,
+		OutputTokens:             a.OutputTokens + b.OutputTokens,
+		CacheCreationInputTokens: a.CacheCreationInputTokens + b.CacheCreationInputTokens,
+		CacheReadInputTokens:     a.CacheReadInputTokens + b.CacheReadInputTokens,
+	}
+}
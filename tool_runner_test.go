@@ -0,0 +1,294 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/liushuangls/go-anthropic/v2/internal/test"
+	"github.com/liushuangls/go-anthropic/v2/internal/test/checks"
+	"github.com/liushuangls/go-anthropic/v2/jsonschema"
+)
+
+var testWeatherTool = anthropic.ToolDefinition{
+	Name: "get_weather",
+	InputSchema: jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"location": {Type: jsonschema.String},
+		},
+		Required: []string{"location"},
+	},
+}
+
+func newTestToolRunner(t *testing.T, client *anthropic.Client, opts ...anthropic.ToolRunnerOption) *anthropic.ToolRunner {
+	t.Helper()
+
+	runner := anthropic.NewToolRunner(client, opts...)
+	err := runner.Register(
+		anthropic.Tool{
+			ToolDefinition: testWeatherTool,
+			Handler: anthropic.TextToolHandler(func(ctx context.Context, input json.RawMessage) (string, error) {
+				var in struct {
+					Location string `json:"location"`
+				}
+				if err := json.Unmarshal(input, &in); err != nil {
+					return "", err
+				}
+				return "65 degrees in " + in.Location, nil
+			}),
+		},
+		anthropic.Tool{
+			ToolDefinition: anthropic.ToolDefinition{Name: "broken", InputSchema: jsonschema.Definition{Type: jsonschema.Object}},
+			Handler: func(ctx context.Context, input json.RawMessage) ([]anthropic.MessageContent, error) {
+				return nil, errors.New("service unavailable")
+			},
+		},
+		anthropic.Tool{
+			ToolDefinition: anthropic.ToolDefinition{Name: "slow", InputSchema: jsonschema.Definition{Type: jsonschema.Object}},
+			Handler: func(ctx context.Context, input json.RawMessage) ([]anthropic.MessageContent, error) {
+				time.Sleep(time.Second)
+				return nil, nil
+			},
+			Timeout: 10 * time.Millisecond,
+		},
+	)
+	checks.NoError(t, err, "Register error")
+	return runner
+}
+
+func TestToolRunner(t *testing.T) {
+	client := newMessagesTestClient(t, handleToolRunnerEndpoint)
+	runner := newTestToolRunner(t, client)
+
+	result, err := runner.Run(context.Background(), anthropic.MessagesRequest{
+		Model:     anthropic.ModelClaude3Haiku20240307,
+		MaxTokens: 1000,
+		Messages: []anthropic.Message{
+			anthropic.NewUserTextMessage("What is the weather like in San Francisco?"),
+		},
+	})
+	checks.NoError(t, err, "Run error")
+
+	if result.Iterations != 2 || len(result.Messages) != 4 {
+		t.Fatalf("unexpected result: %d iterations, %d messages", result.Iterations, len(result.Messages))
+	}
+	if result.Response.GetFirstContentText() != "It is 65 degrees in San Francisco." {
+		t.Fatalf("unexpected final response: %+v", result.Response.Content)
+	}
+	if result.Usage.InputTokens != 30 || result.Usage.OutputTokens != 12 {
+		t.Fatalf("unexpected usage: %+v", result.Usage)
+	}
+}
+
+func TestToolRunnerMaxIterations(t *testing.T) {
+	client := newMessagesTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeToolUseResponse(w, anthropic.NewToolUseMessageContent("toolu_01", "get_weather", json.RawMessage(`{"location":"Paris"}`)))
+	})
+	runner := newTestToolRunner(t, client, anthropic.WithMaxIterations(2))
+
+	result, err := runner.Run(context.Background(), anthropic.MessagesRequest{
+		Model:     anthropic.ModelClaude3Haiku20240307,
+		MaxTokens: 1000,
+		Messages:  []anthropic.Message{anthropic.NewUserTextMessage("What is the weather like in Paris?")},
+	})
+	checks.ErrorIs(t, err, anthropic.ErrToolRunnerMaxIterations, "should stop after 2 iterations")
+	if result.Iterations != 2 || len(result.Messages) != 5 {
+		t.Fatalf("unexpected result: %d iterations, %d messages", result.Iterations, len(result.Messages))
+	}
+}
+
+func TestToolRunnerRegister(t *testing.T) {
+	runner := anthropic.NewToolRunner(anthropic.NewClient(test.GetTestToken()))
+	tool := anthropic.Tool{
+		ToolDefinition: testWeatherTool,
+		Handler: func(ctx context.Context, input json.RawMessage) ([]anthropic.MessageContent, error) {
+			return nil, nil
+		},
+	}
+
+	checks.NoError(t, runner.Register(tool), "Register error")
+	checks.HasError(t, runner.Register(tool), "should not register a tool twice")
+	checks.HasError(t, runner.Register(anthropic.Tool{ToolDefinition: anthropic.ToolDefinition{Name: "no_handler"}}),
+		"should not register a tool without handler")
+}
+
+func TestToolRunnerExecuteToolCalls(t *testing.T) {
+	runner := anthropic.NewToolRunner(anthropic.NewClient(test.GetTestToken()), anthropic.WithToolConcurrency(2))
+
+	var inFlight, maxInFlight atomic.Int32
+	err := runner.Register(anthropic.Tool{
+		ToolDefinition: anthropic.ToolDefinition{Name: "echo"},
+		Handler: anthropic.TextToolHandler(func(ctx context.Context, input json.RawMessage) (string, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				m := maxInFlight.Load()
+				if n <= m || maxInFlight.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return string(input), nil
+		}),
+	})
+	checks.NoError(t, err, "Register error")
+
+	var content []anthropic.MessageContent
+	for i := 0; i < 5; i++ {
+		content = append(content, anthropic.NewToolUseMessageContent(fmt.Sprintf("toolu_%d", i), "echo", json.RawMessage(strconv.Itoa(i))))
+	}
+	message := runner.ExecuteToolCalls(context.Background(), anthropic.MessagesResponse{
+		Content:    append([]anthropic.MessageContent{anthropic.NewTextMessageContent("Echoing.")}, content...),
+		StopReason: anthropic.MessagesStopReasonToolUse,
+	})
+
+	if message.Role != anthropic.RoleUser || len(message.Content) != 5 {
+		t.Fatalf("unexpected message: %+v", message)
+	}
+	for i, result := range message.Content {
+		if *result.ToolUseID != fmt.Sprintf("toolu_%d", i) || result.Content[0].GetText() != strconv.Itoa(i) {
+			t.Fatalf("unexpected result %d: %+v", i, result.MessageContentToolResult)
+		}
+	}
+	if maxInFlight.Load() != 2 {
+		t.Fatalf("expected 2 concurrent calls, got %d", maxInFlight.Load())
+	}
+}
+
+func TestToolChoiceDisableParallelToolUse(t *testing.T) {
+	disable := true
+	bs, err := json.Marshal(anthropic.ToolChoice{Type: "auto", DisableParallelToolUse: &disable})
+	checks.NoError(t, err, "Marshal error")
+	if string(bs) != `{"type":"auto","disable_parallel_tool_use":true}` {
+		t.Fatalf("unexpected tool choice: %s", bs)
+	}
+}
+
+func writeToolUseResponse(w http.ResponseWriter, content ...anthropic.MessageContent) {
+	res := anthropic.MessagesResponse{
+		Type:       "message",
+		Role:       anthropic.RoleAssistant,
+		Content:    content,
+		StopReason: anthropic.MessagesStopReasonToolUse,
+		Usage:      anthropic.MessagesUsage{InputTokens: 10, OutputTokens: 5},
+	}
+	bs, _ := json.Marshal(res)
+	_, _ = w.Write(bs)
+}
+
+func handleToolRunnerEndpoint(w http.ResponseWriter, r *http.Request) {
+	req, err := getMessagesRequest(r)
+	if err != nil {
+		http.Error(w, "could not read request", http.StatusBadRequest)
+		return
+	}
+	if len(req.Tools) != 3 {
+		http.Error(w, "tools not sent", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Messages) == 1 {
+		writeToolUseResponse(w,
+			anthropic.NewTextMessageContent("Let me check."),
+			anthropic.NewToolUseMessageContent("toolu_01", "get_weather", json.RawMessage(`{"location":"San Francisco"}`)),
+			anthropic.NewToolUseMessageContent("toolu_02", "broken", json.RawMessage(`{}`)),
+			anthropic.NewToolUseMessageContent("toolu_03", "missing", json.RawMessage(`{}`)),
+			anthropic.NewToolUseMessageContent("toolu_04", "get_weather", json.RawMessage(`{"city":"San Francisco"}`)),
+			anthropic.NewToolUseMessageContent("toolu_05", "slow", json.RawMessage(`{}`)),
+		)
+		return
+	}
+
+	want := []struct {
+		id      string
+		isError bool
+		text    string
+	}{
+		{"toolu_01", false, "65 degrees in San Francisco"},
+		{"toolu_02", true, "service unavailable"},
+		{"toolu_03", true, `tool "missing" not found`},
+		{"toolu_04", true, `invalid input for tool "get_weather": $.location: required`},
+		{"toolu_05", true, `tool "slow" timed out after 10ms`},
+	}
+	results := req.Messages[len(req.Messages)-1]
+	if results.Role != anthropic.RoleUser || len(results.Content) != len(want) {
+		http.Error(w, "unexpected tool results", http.StatusBadRequest)
+		return
+	}
+	for i, content := range results.Content {
+		if content.Type != anthropic.MessagesContentTypeToolResult || *content.ToolUseID != want[i].id ||
+			*content.IsError != want[i].isError || content.Content[0].GetText() != want[i].text {
+			http.Error(w, fmt.Sprintf("unexpected tool result %d: %+v", i, content.MessageContentToolResult), http.StatusBadRequest)
+			return
+		}
+	}
+
+	res := anthropic.MessagesResponse{
+		Type:       "message",
+		Role:       anthropic.RoleAssistant,
+		Content:    []anthropic.MessageContent{anthropic.NewTextMessageContent("It is 65 degrees in San Francisco.")},
+		StopReason: anthropic.MessagesStopReasonEndTurn,
+		Usage:      anthropic.MessagesUsage{InputTokens: 20, OutputTokens: 7},
+	}
+	bs, _ := json.Marshal(res)
+	_, _ = w.Write(bs)
+}
+
+func TestNewTool(t *testing.T) {
+	type weatherInput struct {
+		Location string `json:"location" jsonschema:"description=The city and state,required"`
+		Unit     string `json:"unit,omitempty" jsonschema:"enum=celsius,enum=fahrenheit"`
+	}
+	type weatherOutput struct {
+		Temperature int    `json:"temperature"`
+		Unit        string `json:"unit"`
+	}
+
+	tool, err := anthropic.NewTool("get_weather", "Get the current weather",
+		func(ctx context.Context, in weatherInput) (weatherOutput, error) {
+			if in.Location == "" {
+				return weatherOutput{}, errors.New("location is empty")
+			}
+			return weatherOutput{Temperature: 18, Unit: in.Unit}, nil
+		})
+	checks.NoError(t, err, "NewTool error")
+
+	schema, ok := tool.InputSchema.(jsonschema.Definition)
+	if !ok || schema.Type != jsonschema.Object || len(schema.Required) != 1 || len(schema.Properties["unit"].Enum) != 2 {
+		t.Fatalf("unexpected input schema: %#v", tool.InputSchema)
+	}
+
+	content, err := tool.Handler(context.Background(), json.RawMessage(`{"location":"Paris","unit":"celsius"}`))
+	checks.NoError(t, err, "Handler error")
+	if len(content) != 1 || content[0].GetText() != `{"temperature":18,"unit":"celsius"}` {
+		t.Fatalf("unexpected content: %+v", content)
+	}
+
+	_, err = tool.Handler(context.Background(), json.RawMessage(`{"location":""}`))
+	checks.HasError(t, err, "should return the function error")
+
+	textTool, err := anthropic.NewTool("echo", "Echo the text",
+		func(ctx context.Context, in struct{ Text string }) (string, error) {
+			return in.Text, nil
+		})
+	checks.NoError(t, err, "NewTool error")
+	content, err = textTool.Handler(context.Background(), json.RawMessage(`{"Text":"hello"}`))
+	checks.NoError(t, err, "Handler error")
+	if len(content) != 1 || content[0].GetText() != "hello" {
+		t.Fatalf("unexpected content: %+v", content)
+	}
+
+	_, err = anthropic.NewTool("invalid", "Input is not an object",
+		func(ctx context.Context, in string) (string, error) {
+			return in, nil
+		})
+	checks.HasError(t, err, "should require an object input")
+}
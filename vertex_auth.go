@@ -0,0 +1,238 @@
+package anthropic
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultGoogleTokenURL = "https://oauth2.googleapis.com/token"
+	vertexAIScope         = "https://www.googleapis.com/auth/cloud-platform"
+	jwtBearerGrantType    = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+	// serviceAccountTokenLifetime is the lifetime requested for the access tokens, the maximum Google allows.
+	serviceAccountTokenLifetime = time.Hour
+	// serviceAccountTokenRefreshMargin is how long before its expiry an access token is refreshed.
+	serviceAccountTokenRefreshMargin = time.Minute
+	// serviceAccountTokenFetchTimeout bounds a token fetch, which is not canceled along with the request starting it.
+	serviceAccountTokenFetchTimeout = 30 * time.Second
+)
+
+// WithVertexAIServiceAccount authenticates the Vertex AI requests with the access tokens of a
+// Google Cloud service account, given its JSON key. The tokens are obtained with a JWT signed with
+// the key, from the token_uri of the key, and cached until a minute before they expire.
+// It must be used along with WithVertexAI, and an invalid key fails the requests.
+func WithVertexAIServiceAccount(jsonKey []byte) ClientOption {
+	return func(c *ClientConfig) {
+		c.vertexTokenSource = newServiceAccountTokenSource(jsonKey)
+	}
+}
+
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// serviceAccountTokenSource exchanges signed JWT assertions for access tokens, and caches them.
+type serviceAccountTokenSource struct {
+	key        serviceAccountKey
+	privateKey *rsa.PrivateKey
+	// err is the error parsing the key, returned by token
+	err error
+
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+	// refresh is the token fetch in progress, nil if there is none
+	refresh *tokenRefresh
+}
+
+// tokenRefresh is a token fetch shared by the concurrent callers, done is closed when it completes.
+type tokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+func newServiceAccountTokenSource(jsonKey []byte) *serviceAccountTokenSource {
+	s := &serviceAccountTokenSource{}
+	if err := json.Unmarshal(jsonKey, &s.key); err != nil {
+		s.err = fmt.Errorf("vertex ai: invalid service account key: %w", err)
+		return s
+	}
+	if s.key.Type != "service_account" || s.key.ClientEmail == "" {
+		s.err = errors.New("vertex ai: invalid service account key: not a service account key")
+		return s
+	}
+	if s.key.TokenURI == "" {
+		s.key.TokenURI = defaultGoogleTokenURL
+	}
+	s.privateKey, s.err = parseRSAPrivateKey(s.key.PrivateKey)
+	return s
+}
+
+func parseRSAPrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("vertex ai: invalid service account key: no PEM private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("vertex ai: invalid service account key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("vertex ai: invalid service account key: not an RSA private key")
+	}
+	return key, nil
+}
+
+// token returns the cached access token, or fetches a new one with the HTTP client if it expires soon.
+// Concurrent callers wait for a single fetch, each until its own ctx is done.
+func (s *serviceAccountTokenSource) token(ctx context.Context, client *http.Client) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+
+	s.mu.Lock()
+	if s.accessToken != "" && time.Now().Add(serviceAccountTokenRefreshMargin).Before(s.expiry) {
+		token := s.accessToken
+		s.mu.Unlock()
+		return token, nil
+	}
+	r := s.refresh
+	if r == nil {
+		r = &tokenRefresh{done: make(chan struct{})}
+		s.refresh = r
+		go s.refreshToken(context.WithoutCancel(ctx), client, r)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-r.done:
+		return r.token, r.err
+	}
+}
+
+// refreshToken fetches a new access token for the callers waiting for r, caching it on success.
+func (s *serviceAccountTokenSource) refreshToken(ctx context.Context, client *http.Client, r *tokenRefresh) {
+	ctx, cancel := context.WithTimeout(ctx, serviceAccountTokenFetchTimeout)
+	defer cancel()
+
+	token, expiry, err := s.fetchToken(ctx, client)
+
+	s.mu.Lock()
+	if err == nil {
+		s.accessToken, s.expiry = token, expiry
+	}
+	s.refresh = nil
+	s.mu.Unlock()
+
+	r.token, r.err = token, err
+	close(r.done)
+}
+
+func (s *serviceAccountTokenSource) fetchToken(ctx context.Context, client *http.Client) (string, time.Time, error) {
+	now := time.Now()
+	assertion, err := s.signAssertion(now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	form := url.Values{
+		"grant_type": {jwtBearerGrantType},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.key.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("vertex ai: fetch access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("vertex ai: fetch access token: %w", err)
+	}
+
+	var tokenRes struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &tokenRes); err != nil && resp.StatusCode == http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("vertex ai: invalid access token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("vertex ai: fetch access token: status code %d: %s %s",
+			resp.StatusCode, tokenRes.Error, tokenRes.ErrorDescription)
+	}
+	if tokenRes.AccessToken == "" {
+		return "", time.Time{}, errors.New("vertex ai: the access token response has no access token")
+	}
+	lifetime := time.Duration(tokenRes.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = serviceAccountTokenLifetime
+	}
+	return tokenRes.AccessToken, now.Add(lifetime), nil
+}
+
+// signAssertion returns the JWT assertion requesting an access token, signed with RS256.
+func (s *serviceAccountTokenSource) signAssertion(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"kid": s.key.PrivateKeyID,
+	})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   s.key.ClientEmail,
+		"scope": vertexAIScope,
+		"aud":   s.key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(serviceAccountTokenLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
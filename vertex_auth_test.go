@@ -0,0 +1,226 @@
+package anthropic_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/liushuangls/go-anthropic/v2/internal/test/checks"
+)
+
+// vertexTestServer serves a token endpoint checking the JWT assertions against the public key,
+// and a messages endpoint checking the access token.
+type vertexTestServer struct {
+	t         *testing.T
+	publicKey *rsa.PublicKey
+	expiresIn int
+	// delay is how long the token endpoint takes to respond
+	delay time.Duration
+	// tokenCalls counts the access tokens issued, the Nth being "token-N"
+	tokenCalls atomic.Int32
+	server     *httptest.Server
+}
+
+func newVertexTestServer(t *testing.T, publicKey *rsa.PublicKey, expiresIn int) *vertexTestServer {
+	s := &vertexTestServer{t: t, publicKey: publicKey, expiresIn: expiresIn}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", s.handleToken)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer token-") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"type":"message","role":"assistant","content":[{"type":"text","text":"` + r.Header.Get("Authorization") + `"}]}`))
+	})
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *vertexTestServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(s.delay)
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+		http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+		return
+	}
+
+	parts := strings.Split(r.Form.Get("assertion"), ".")
+	if len(parts) != 3 {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(s.publicKey, crypto.SHA256, hash[:], signature); err != nil {
+		http.Error(w, `{"error":"invalid_grant","error_description":"Invalid JWT Signature."}`, http.StatusBadRequest)
+		return
+	}
+
+	var claims map[string]any
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		s.t.Errorf("invalid claims: %v", err)
+	}
+	if claims["iss"] != "test@project.iam.gserviceaccount.com" || claims["aud"] != s.server.URL+"/token" ||
+		claims["scope"] != "https://www.googleapis.com/auth/cloud-platform" {
+		s.t.Errorf("unexpected claims: %v", claims)
+	}
+
+	n := s.tokenCalls.Add(1)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token": fmt.Sprintf("token-%d", n),
+		"expires_in":   s.expiresIn,
+		"token_type":   "Bearer",
+	})
+}
+
+func newTestServiceAccountKey(t *testing.T, key *rsa.PrivateKey, tokenURI string) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonKey, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "project",
+		"private_key_id": "key-id",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "test@project.iam.gserviceaccount.com",
+		"token_uri":      tokenURI,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jsonKey
+}
+
+func newVertexServiceAccountTestClient(t *testing.T, expiresIn int) (*anthropic.Client, *vertexTestServer) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newVertexTestServer(t, &key.PublicKey, expiresIn)
+
+	client := anthropic.NewClient("",
+		anthropic.WithVertexAI("project", "us-east5"),
+		anthropic.WithVertexAIServiceAccount(newTestServiceAccountKey(t, key, server.server.URL+"/token")),
+		anthropic.WithBaseURL(server.server.URL),
+	)
+	return client, server
+}
+
+func TestVertexAIServiceAccount(t *testing.T) {
+	client, server := newVertexServiceAccountTestClient(t, 3600)
+
+	// concurrent requests share a single access token
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+			checks.NoError(t, err, "CreateMessages error")
+			if resp.GetFirstContentText() != "Bearer token-1" {
+				t.Errorf("unexpected authorization: %q", resp.GetFirstContentText())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls := server.tokenCalls.Load(); calls != 1 {
+		t.Errorf("the token endpoint was called %d times, want 1", calls)
+	}
+}
+
+func TestVertexAIServiceAccountRefresh(t *testing.T) {
+	// tokens expiring within a minute are refreshed before each request
+	client, server := newVertexServiceAccountTestClient(t, 30)
+
+	for i, want := range []string{"Bearer token-1", "Bearer token-2"} {
+		resp, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+		checks.NoError(t, err, "CreateMessages error")
+		if resp.GetFirstContentText() != want {
+			t.Errorf("request %d authorization = %q, want %q", i, resp.GetFirstContentText(), want)
+		}
+	}
+	if calls := server.tokenCalls.Load(); calls != 2 {
+		t.Errorf("the token endpoint was called %d times, want 2", calls)
+	}
+}
+
+func TestVertexAIServiceAccountNoExpiry(t *testing.T) {
+	// tokens without expires_in are cached for the requested lifetime
+	client, server := newVertexServiceAccountTestClient(t, 0)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+		checks.NoError(t, err, "CreateMessages error")
+	}
+	if calls := server.tokenCalls.Load(); calls != 1 {
+		t.Errorf("the token endpoint was called %d times, want 1", calls)
+	}
+}
+
+func TestVertexAIServiceAccountCanceledCaller(t *testing.T) {
+	client, server := newVertexServiceAccountTestClient(t, 3600)
+	server.delay = 100 * time.Millisecond
+
+	// a caller giving up does not cancel the fetch the other callers wait for
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.CreateMessages(ctx, newTestMessagesRequest())
+	checks.ErrorIs(t, err, context.DeadlineExceeded, "the canceled caller should not wait for the token")
+
+	resp, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+	checks.NoError(t, err, "CreateMessages error")
+	if resp.GetFirstContentText() != "Bearer token-1" {
+		t.Errorf("unexpected authorization: %q", resp.GetFirstContentText())
+	}
+	if calls := server.tokenCalls.Load(); calls != 1 {
+		t.Errorf("the token endpoint was called %d times, want 1", calls)
+	}
+}
+
+func TestVertexAIServiceAccountErrors(t *testing.T) {
+	t.Run("invalid key", func(t *testing.T) {
+		client := anthropic.NewClient("",
+			anthropic.WithVertexAI("project", "us-east5"),
+			anthropic.WithVertexAIServiceAccount([]byte(`{"type":"authorized_user"}`)),
+		)
+		_, err := client.CreateMessages(context.Background(), newTestMessagesRequest())
+		if err == nil || !strings.Contains(err.Error(), "invalid service account key") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("rejected assertion", func(t *testing.T) {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client, server := newVertexServiceAccountTestClient(t, 3600)
+		server.publicKey = &otherKey.PublicKey
+
+		_, err = client.CreateMessages(context.Background(), newTestMessagesRequest())
+		if err == nil || !strings.Contains(err.Error(), "Invalid JWT Signature.") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
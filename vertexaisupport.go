@@ -4,6 +4,7 @@ package anthropic
 // The changes are defined here: https://docs.anthropic.com/en/api/claude-on-vertex-ai
 // Model needs to be in the calling URL
 // The version of the API is defined in the request body
+// Amazon Bedrock requests are configured the same way: https://docs.anthropic.com/en/api/claude-on-amazon-bedrock
 // This interface allows the vertex ai changes to be contained in the client code, and not leak to each indivdual request definition.
 type VertexAISupport interface {
 	GetModel() string
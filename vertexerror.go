@@ -1,6 +1,7 @@
 package anthropic
 
 import (
+	"encoding/json"
 	"fmt"
 )
 
@@ -21,35 +22,58 @@ type VertexAPIErrorDetails struct {
 	} `json:"metadata"`
 }
 
-/*
+// vertexStatusErrTypes maps the gRPC status names of the Vertex AI errors to the Anthropic error types.
+var vertexStatusErrTypes = map[string]ErrType{
+	"INVALID_ARGUMENT":    ErrTypeInvalidRequest,
+	"FAILED_PRECONDITION": ErrTypeInvalidRequest,
+	"OUT_OF_RANGE":        ErrTypeInvalidRequest,
+	"UNAUTHENTICATED":     ErrTypeAuthentication,
+	"PERMISSION_DENIED":   ErrTypePermission,
+	"NOT_FOUND":           ErrTypeNotFound,
+	"RESOURCE_EXHAUSTED":  ErrTypeRateLimit,
+	"UNAVAILABLE":         ErrTypeOverloaded,
+	"INTERNAL":            ErrTypeApi,
+	"UNKNOWN":             ErrTypeApi,
+	"DEADLINE_EXCEEDED":   ErrTypeApi,
+	"DATA_LOSS":           ErrTypeApi,
+}
+
+// Type returns the Anthropic error type matching the error's status,
+// or its HTTP status code for an unknown status.
+func (e *VertexAPIError) Type() ErrType {
+	if errType, ok := vertexStatusErrTypes[e.Status]; ok {
+		return errType
+	}
+	return errTypeFromStatusCode(e.Code)
+}
+
 func (e *VertexAPIError) IsInvalidRequestErr() bool {
-	return e.Type == ErrTypeInvalidRequest
+	return e.Type() == ErrTypeInvalidRequest
 }
 
 func (e *VertexAPIError) IsAuthenticationErr() bool {
-	return e.Type == ErrTypeAuthentication
+	return e.Type() == ErrTypeAuthentication
 }
 
 func (e *VertexAPIError) IsPermissionErr() bool {
-	return e.Type == ErrTypePermission
+	return e.Type() == ErrTypePermission
 }
 
 func (e *VertexAPIError) IsNotFoundErr() bool {
-	return e.Type == ErrTypeNotFound
+	return e.Type() == ErrTypeNotFound
 }
 
 func (e *VertexAPIError) IsRateLimitErr() bool {
-	return e.Type == ErrTypeRateLimit
+	return e.Type() == ErrTypeRateLimit
 }
 
 func (e *VertexAPIError) IsApiErr() bool {
-	return e.Type == ErrTypeApi
+	return e.Type() == ErrTypeApi
 }
 
 func (e *VertexAPIError) IsOverloadedErr() bool {
-	return e.Type == ErrTypeOverloaded
+	return e.Type() == ErrTypeOverloaded
 }
-*/
 
 type VertexAIErrorResponse struct {
 	Error *VertexAPIError `json:"error,omitempty"`
@@ -58,3 +82,21 @@ type VertexAIErrorResponse struct {
 func (e *VertexAPIError) Error() string {
 	return fmt.Sprintf("vertex api error code: %d, status: %s, message: %s", e.Code, e.Status, e.Message)
 }
+
+// parseVertexErrorBody decodes a Vertex AI error body, which can be an object or an array
+// of objects. It returns nil if the body is not in that format.
+func parseVertexErrorBody(bodyBytes []byte) *VertexAPIError {
+	var errRes VertexAIErrorResponse
+	if err := json.Unmarshal(bodyBytes, &errRes); err != nil {
+		var errResArr []VertexAIErrorResponse
+		if err := json.Unmarshal(bodyBytes, &errResArr); err != nil || len(errResArr) == 0 {
+			return nil
+		}
+		errRes = errResArr[0]
+	}
+	// an Anthropic error also has an error object, but without code and status
+	if errRes.Error == nil || (errRes.Error.Code == 0 && errRes.Error.Status == "") {
+		return nil
+	}
+	return errRes.Error
+}